@@ -0,0 +1,246 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/botcliq/loadzy/internal/pkg/stats"
+	"github.com/botcliq/loadzy/internal/pkg/testdef"
+)
+
+const usage = `Usage: loadzy <command> [flags] [spec.yml | results file]
+
+Commands:
+  run       run the load test of a YAML spec (the default command)
+  validate  check a YAML spec without running it
+  report    print the report of a recorded run
+  convert   convert a recorded run to gob, json or csv
+
+Run 'loadzy <command> -h' to list the flags of a command.
+`
+
+// Name of the file run records every result into, inside the output directory.
+const resultsFile = "results.gob"
+
+type runOptions struct {
+	spec      string
+	out       string
+	dashboard string
+	quiet     bool
+	grace     time.Duration
+	overrides overrides
+}
+
+// overrides are settings of the spec given on the command line.
+type overrides struct {
+	users      int
+	rate       int
+	iterations int
+	duration   time.Duration
+	workers    int
+	set        map[string]bool
+}
+
+func (o *overrides) flags(fs *flag.FlagSet) {
+	fs.IntVar(&o.users, "users", 0, "override the number of users")
+	fs.IntVar(&o.rate, "rate", 0, "override the rate, per second (of iterations for arrival-rate executors, else of requests)")
+	fs.IntVar(&o.iterations, "iterations", 0, "override the number of iterations")
+	fs.DurationVar(&o.duration, "duration", 0, "override the duration, e.g. 5m")
+	fs.IntVar(&o.workers, "workers", 0, "run a fixed number of workers instead of the pool size of the spec")
+}
+
+func (o overrides) apply(t *testdef.TestDef) {
+	if o.set["users"] {
+		t.Users = o.users
+	}
+	if o.set["rate"] {
+		if t.Executor.IsArrivalRate() {
+			t.Executor.Rate = float64(o.rate)
+		} else {
+			t.Rate = o.rate
+		}
+	}
+	if o.set["iterations"] {
+		t.Iterations = o.iterations
+	}
+	if o.set["duration"] {
+		t.Duration = testdef.Duration(o.duration)
+	}
+	if o.set["workers"] {
+		t.Workers = testdef.Workers{Min: o.workers, Max: o.workers}
+	}
+}
+
+// parse parses args with fs, also accepting flags after the positional arguments,
+// and returns the positional arguments.
+func parse(fs *flag.FlagSet, args []string) []string {
+	var positional []string
+	for {
+		fs.Parse(args)
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// specPath returns the spec given by the -spec flag or else as the only argument.
+func specPath(fs *flag.FlagSet, spec string, args []string) string {
+	if spec == "" && len(args) == 1 {
+		spec = args[0]
+	} else if len(args) > 0 {
+		spec = ""
+	}
+	if spec == "" {
+		fmt.Fprintf(os.Stderr, "loadzy %s: expected one YAML spec\n", fs.Name())
+		fs.Usage()
+		os.Exit(2)
+	}
+	return spec
+}
+
+func runCommand(args []string) {
+	var opts runOptions
+	fs := flag.NewFlagSet("run", flag.ExitOnError)
+	fs.StringVar(&opts.spec, "spec", "", "path of the YAML spec")
+	fs.StringVar(&opts.out, "out", "results", "output directory for the results")
+	fs.StringVar(&opts.dashboard, "dashboard", "localhost:8182", "address of the live dashboard, empty to disable it")
+	fs.BoolVar(&opts.quiet, "quiet", false, "only print the final report")
+	fs.DurationVar(&opts.grace, "grace", 30*time.Second, "how long in-flight requests may take to finish when interrupted")
+	opts.overrides.flags(fs)
+	opts.spec = specPath(fs, opts.spec, parse(fs, args))
+	opts.overrides.set = setFlags(fs)
+
+	log.SetFlags(0)
+	run(opts)
+}
+
+func validateCommand(args []string) {
+	var spec string
+	var o overrides
+	fs := flag.NewFlagSet("validate", flag.ExitOnError)
+	fs.StringVar(&spec, "spec", "", "path of the YAML spec")
+	o.flags(fs)
+	spec = specPath(fs, spec, parse(fs, args))
+	o.set = setFlags(fs)
+
+	log.SetFlags(0)
+	_, _, _, err := loadSpec(spec, o)
+	fail(err)
+	fmt.Printf("%s is valid\n", spec)
+}
+
+func reportCommand(args []string) {
+	fs := flag.NewFlagSet("report", flag.ExitOnError)
+	typ := fs.String("type", "text", "report type, text or json")
+	groupBy := fs.String("group-by", stats.GroupByTitle, "group results by title or request")
+	output := fs.String("output", "", "file to write the report to, stdout if empty")
+	files := parse(fs, args)
+	if len(files) == 0 {
+		files = []string{filepath.Join("results", resultsFile)}
+	}
+
+	stats.SetGroupBy(*groupBy)
+	var m stats.Metrics
+	for _, name := range files {
+		dec, closer, err := openResults(name)
+		fail(err)
+		err = m.Replay(dec)
+		closer.Close()
+		fail(err)
+	}
+	m.Close()
+
+	var rep stats.Reporter
+	switch *typ {
+	case "text":
+		rep = stats.NewTextReporter(&m)
+	case "json":
+		rep = stats.NewJSONReporter(&m)
+	default:
+		fail(fmt.Errorf("unknown report type '%s', must be text or json", *typ))
+	}
+	w, closer := createOutput(*output)
+	defer closer.Close()
+	fail(rep.Report(w))
+}
+
+func convertCommand(args []string) {
+	fs := flag.NewFlagSet("convert", flag.ExitOnError)
+	to := fs.String("to", "json", "format to convert to, gob, json or csv")
+	output := fs.String("output", "", "file to write to, stdout if empty")
+	files := parse(fs, args)
+	if len(files) == 0 {
+		files = []string{filepath.Join("results", resultsFile)}
+	}
+
+	w, closer := createOutput(*output)
+	defer closer.Close()
+	bw := bufio.NewWriter(w)
+	var enc stats.Encoder
+	switch *to {
+	case "gob":
+		enc = stats.NewEncoder(bw)
+	case "json":
+		enc = stats.NewJSONEncoder(bw)
+	case "csv":
+		enc = stats.NewCSVEncoder(bw)
+	default:
+		fail(fmt.Errorf("unknown format '%s', must be gob, json or csv", *to))
+	}
+	for _, name := range files {
+		dec, closer, err := openResults(name)
+		fail(err)
+		for {
+			var r stats.Result
+			if err = dec.Decode(&r); err != nil {
+				break
+			}
+			fail(enc.Encode(&r))
+		}
+		closer.Close()
+		if err != io.EOF {
+			fail(err)
+		}
+	}
+	fail(bw.Flush())
+}
+
+// openResults opens a recorded run in any of the formats convert writes.
+func openResults(name string) (stats.Decoder, io.Closer, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	dec := stats.DecoderFor(f)
+	if dec == nil {
+		f.Close()
+		return nil, nil, fmt.Errorf("%s: not a gob, json or csv results file", name)
+	}
+	return dec, f, nil
+}
+
+// createOutput creates the file name, or returns stdout if name is empty.
+func createOutput(name string) (io.Writer, io.Closer) {
+	if name == "" {
+		return os.Stdout, ioutil.NopCloser(nil)
+	}
+	f, err := os.Create(name)
+	fail(err)
+	return f, f
+}
+
+func setFlags(fs *flag.FlagSet) map[string]bool {
+	set := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	return set
+}
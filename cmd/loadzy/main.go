@@ -24,132 +24,272 @@ SOFTWARE.
 package main
 
 import (
+	"bufio"
+	"context"
 	"fmt"
 	"io/ioutil"
 	"log"
 	"math/rand"
 	"os"
+	"os/signal"
+	"path/filepath"
 	"strconv"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/botcliq/loadzy/internal/pkg/action"
 	"github.com/botcliq/loadzy/internal/pkg/feeder"
 	"github.com/botcliq/loadzy/internal/pkg/result"
 	"github.com/botcliq/loadzy/internal/pkg/runtime"
+	"github.com/botcliq/loadzy/internal/pkg/scheduler"
 	ws "github.com/botcliq/loadzy/internal/pkg/server"
 	"github.com/botcliq/loadzy/internal/pkg/stats"
 	"github.com/botcliq/loadzy/internal/pkg/testdef"
 	"github.com/botcliq/loadzy/internal/pkg/user"
 	"github.com/botcliq/loadzy/internal/pkg/workers"
-	"go.uber.org/ratelimit"
 	"gopkg.in/yaml.v2"
 	//"github.com/davecheney/profile"
 )
 
 func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	switch os.Args[1] {
+	case "run":
+		runCommand(os.Args[2:])
+	case "validate":
+		validateCommand(os.Args[2:])
+	case "report":
+		reportCommand(os.Args[2:])
+	case "convert":
+		convertCommand(os.Args[2:])
+	case "help", "-h", "-help", "--help":
+		fmt.Print(usage)
+	default:
+		// Plain `loadzy spec.yml` from before there were subcommands.
+		runCommand(os.Args[1:])
+	}
+}
 
-	spec := parseSpecFile()
+// run runs the test spec of opts, writing the results into the output directory.
+func run(opts runOptions) {
 
 	// defer profile.Start(profile.CPUProfile).Stop()
 
-	// Start the web socket server, will not block exit until forced
-	go ws.StartWsServer()
+	if opts.dashboard != "" {
+		// Start the web socket server, will not block exit until forced
+		go ws.StartWsServer(opts.dashboard)
+	}
 	stats.ClearOrAddMetrics(1)
 	_ = stats.Slowest()
 	runtime.SimulationStart = time.Now()
-	dir, _ := os.Getwd()
-	dat, _ := ioutil.ReadFile(dir + "/" + spec)
 
-	var t testdef.TestDef
-	err := yaml.Unmarshal([]byte(dat), &t)
+	t, scenarios, thresholds, err := loadSpec(opts.spec, opts.overrides)
 	fail(err)
 
-	if !testdef.ValidateTestDefinition(&t) {
-		return
-	}
-
-	actions, isValid := action.BuildActionList(&t)
-	if !isValid {
-		return
-	}
-
 	if t.Feeder.Type == "csv" {
 		feeder.Csv(t.Feeder.Filename, ",")
 	} else if t.Feeder.Type != "" {
 		log.Fatal("Unsupported feeder type: " + t.Feeder.Type)
 	}
+	// Only silence the log once the spec has loaded, so -quiet still shows what is
+	// wrong with an invalid one.
+	if opts.quiet {
+		log.SetOutput(ioutil.Discard)
+	}
 
-	result.OpenResultsFile(dir + "/results/log/latest.log")
-	RunTraffic(&t, actions)
+	fail(os.MkdirAll(opts.out, 0777))
+	result.OpenResultsFile(filepath.Join(opts.out, "log", "latest.log"))
+	f, err := os.Create(filepath.Join(opts.out, resultsFile))
+	fail(err)
+	w := bufio.NewWriter(f)
+	stats.Record(stats.NewEncoder(w))
+
+	ctx, interrupted := interruptible(opts.grace)
+	passed, err := RunTraffic(ctx, t, scenarios, thresholds, opts.grace)
 
-	fmt.Printf("Done in %v\n", time.Since(runtime.SimulationStart))
-	fmt.Println("Building reports, please wait...")
+	stats.Record(nil)
+	fail(w.Flush())
+	fail(f.Close())
+	log.Printf("Done in %v\n", time.Since(runtime.SimulationStart))
+	log.Println("Building reports, please wait...")
 	result.CloseResultsFile()
 	//buildReport()
+	fail(err)
+	if !passed {
+		os.Exit(exitThresholdsBreached)
+	}
+	if interrupted() {
+		os.Exit(exitInterrupted)
+	}
+}
+
+// interruptible returns a context that is cancelled on the first SIGINT or SIGTERM,
+// and a func telling whether that happened. A second signal kills the process.
+func interruptible(grace time.Duration) (context.Context, func() bool) {
+	ctx, cancel := context.WithCancel(context.Background())
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		sig := <-sigs
+		signal.Stop(sigs)
+		fmt.Fprintf(os.Stderr, "Got %v, stopping and waiting up to %v for in-flight requests (repeat to quit now)\n", sig, grace)
+		cancel()
+	}()
+	return ctx, func() bool { return ctx.Err() != nil }
+}
+
+// scenario is a group of users running the same actions, see testdef.Scenario. A test
+// without scenarios runs as a single scenario without a name.
+type scenario struct {
+	name    string
+	t       *testdef.TestDef
+	actions []action.Action
 }
 
-func parseSpecFile() string {
-	if len(os.Args) == 1 {
-		fmt.Println("No command line arguments, exiting...")
-		panic("Cannot start simulation, no YAML simulaton specification supplied as command-line argument")
+// loadSpec reads the test spec at path, applies the command line overrides and
+// validates it.
+func loadSpec(path string, o overrides) (*testdef.TestDef, []scenario, []stats.Threshold, error) {
+	dat, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	var t testdef.TestDef
+	if err = yaml.Unmarshal(dat, &t); err != nil {
+		return nil, nil, nil, err
 	}
-	var s, sep string
-	for i := 1; i < len(os.Args); i++ {
-		s += sep + os.Args[i]
-		sep = " "
+	o.apply(&t)
+
+	if !testdef.ValidateTestDefinition(&t) {
+		return nil, nil, nil, fmt.Errorf("invalid test definition '%s'", path)
 	}
-	if s == "" {
-		panic(fmt.Sprintf("Specified simulation file '%s' is not a .yml file", s))
+
+	var scenarios []scenario
+	for _, d := range t.ScenarioDefs() {
+		actions, isValid := action.BuildActionList(d)
+		if !isValid {
+			return nil, nil, nil, fmt.Errorf("invalid actions in '%s'", path)
+		}
+		scenarios = append(scenarios, scenario{d.Scenario, d, actions})
+	}
+
+	thresholds, err := parseThresholds(&t)
+	if err != nil {
+		return nil, nil, nil, err
 	}
-	return s
+	if t.GroupBy != "" {
+		stats.SetGroupBy(t.GroupBy)
+	}
+	return &t, scenarios, thresholds, nil
+}
+
+// Exit code used when the run completed but one or more thresholds were breached.
+const exitThresholdsBreached = 99
+
+// Exit code used when the run was stopped early by a signal.
+const exitInterrupted = 130
+
+func parseThresholds(t *testdef.TestDef) ([]stats.Threshold, error) {
+	var thresholds []stats.Threshold
+	for _, group := range t.Thresholds {
+		for _, rule := range group.Rules {
+			th, err := stats.ParseThreshold(group.Title, rule)
+			if err != nil {
+				return nil, err
+			}
+			thresholds = append(thresholds, th)
+		}
+	}
+	return thresholds, nil
 }
 
+var usersMu sync.Mutex
 var userMap map[int]*user.User
 var Limiter chan *workers.Task
 
-func RunTraffic(t *testdef.TestDef, actions []action.Action) {
+// abortRun stops the run when an action with the abort onError policy fails.
+var abortRun func(error)
+
+// RunTraffic runs the test and prints its report. It returns false if any of the
+// thresholds was breached, and an error if the run was aborted by a failing action.
+// Once ctx is cancelled no more requests are started, and the ones in flight get the
+// grace period to finish before they are aborted.
+func RunTraffic(ctx context.Context, t *testdef.TestDef, scenarios []scenario, thresholds []stats.Threshold, grace time.Duration) (bool, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var aborted error
+	var abortOnce sync.Once
+	abortRun = func(err error) {
+		abortOnce.Do(func() {
+			aborted = fmt.Errorf("run aborted by %v", err)
+			cancel()
+		})
+	}
+
 	// create worker pool
-	fmt.Println("Creating worker pool.")
-	p := workers.GetPool(20)
-	p.Run()
+	min, max := t.PoolSize()
+	log.Printf("Creating worker pool of %d to %d workers.\n", min, max)
+	p := workers.NewPool(min, max)
+	p.Run(ctx)
+	result.PoolStats = p.Stats
 	// #create channel for user to use
-	fmt.Println("Creating limiter")
+	log.Println("Creating limiter")
 	Limiter = make(chan *workers.Task, 1000)
 	resultsChannel := make(chan result.HttpReqResult, 10000) // buffer?
 	go result.AcceptResults(resultsChannel)
 	wg := sync.WaitGroup{}
 	// create rate limiter.
-	rl := ratelimit.New(t.Rate)
+	rl := scheduler.NewRateLimiter(t.Rate)
 	userMap = make(map[int]*user.User)
-	wg.Add(1)
-	go func() {
-		for i := 1; i <= t.Users; i++ {
-			// Create new users
-			// fmt.Println("Creating new user.")
-			u := user.New(i, Limiter)
-			userMap[u.Id] = u
-			wg.Add(1)
-			UID := strconv.Itoa(rand.Intn(t.Users+1) + 10000)
-			go u.LaunchActions(t, resultsChannel, &wg, actions, UID)
-			var waitDuration float32 = float32(t.Rampup) / float32(t.Users)
-			time.Sleep(time.Duration(int(1000*waitDuration)) * time.Millisecond)
+	for i := range scenarios {
+		s := &scenarios[i]
+		if s.name != "" {
+			log.Printf("Starting scenario %s with %d users.\n", s.name, s.t.PeakUsers())
 		}
-		wg.Done()
-	}()
+		wg.Add(1)
+		if s.t.Executor.IsArrivalRate() {
+			go runArrivalRate(ctx, s, resultsChannel, &wg)
+		} else if len(s.t.Stages) > 0 {
+			go runStages(ctx, s, resultsChannel, rl, &wg)
+		} else {
+			go runUsers(ctx, s, resultsChannel, &wg)
+		}
+	}
 	// at specified rate read from the select.
-	fmt.Println("Waiting for date on the select !!")
+	log.Println("Waiting for date on the select !!")
 
 	go func() {
 		for {
 			select {
 			case c := <-Limiter:
-				_ = rl.Take()
+				// Once stopped the workers skip the task anyway, don't hold it up.
+				if ctx.Err() == nil {
+					_ = rl.Take()
+				}
 				p.Collector <- c
 			}
 		}
 	}()
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-done:
+			return
+		}
+		select {
+		case <-time.After(grace):
+			log.Println("Grace period is over, aborting in-flight requests")
+			p.Abort()
+		case <-done:
+		}
+	}()
 	wg.Wait()
+	close(done)
 	mt := stats.GetMetric(1)
 	if mt != nil {
 		mt.Close()
@@ -157,6 +297,135 @@ func RunTraffic(t *testdef.TestDef, actions []action.Action) {
 
 		tRep := stats.NewTextReporter(mt)
 		tRep.Report(os.Stdout)
+
+		if len(thresholds) > 0 {
+			fmt.Println()
+			stats.NewThresholdReporter(mt, thresholds).Report(os.Stdout)
+			return stats.CheckThresholds(mt, thresholds), aborted
+		}
+	}
+	return true, aborted
+}
+
+// runUsers starts the users of s one after the other, spread over its rampup.
+func runUsers(ctx context.Context, s *scenario, resultsChannel chan result.HttpReqResult, wg *sync.WaitGroup) {
+	defer wg.Done()
+	t := s.t
+	for i := 1; i <= t.Users && ctx.Err() == nil; i++ {
+		// Create new users
+		// fmt.Println("Creating new user.")
+		startUser(ctx, s, resultsChannel, wg)
+		var waitDuration float32 = float32(t.Rampup) / float32(t.Users)
+		sleep(ctx, time.Duration(int(1000*waitDuration))*time.Millisecond)
+	}
+}
+
+// newUser returns a new user of s, with an id unique across all scenarios.
+func newUser(s *scenario) *user.User {
+	usersMu.Lock()
+	defer usersMu.Unlock()
+	u := user.New(len(userMap)+1, Limiter, abortRun)
+	u.Scenario = s.name
+	userMap[u.Id] = u
+	return u
+}
+
+func startUser(ctx context.Context, s *scenario, resultsChannel chan result.HttpReqResult, wg *sync.WaitGroup) *user.User {
+	u := newUser(s)
+	wg.Add(1)
+	go u.LaunchActions(ctx, s.t, resultsChannel, wg, s.actions, newUID(s.t))
+	return u
+}
+
+func newUID(t *testdef.TestDef) string {
+	return strconv.Itoa(rand.Intn(t.Users+1) + 10000)
+}
+
+// runArrivalRate starts iterations at the arrival rate of the executor of s on idle
+// users from a pool of its preallocated users, no matter how long earlier
+// iterations take. If no user is idle when an iteration is due it is dropped and
+// counted instead of delaying the schedule. No iterations are started after ctx is cancelled.
+func runArrivalRate(ctx context.Context, s *scenario, resultsChannel chan result.HttpReqResult, wg *sync.WaitGroup) {
+	defer wg.Done()
+	t := s.t
+	idle := make(chan *user.User, t.Users)
+	for i := 1; i <= t.Users; i++ {
+		u := newUser(s)
+		u.UID = newUID(t)
+		idle <- u
+	}
+	rnd := rand.New(rand.NewSource(time.Now().UnixNano()))
+	deadline := t.Deadline(runtime.SimulationStart)
+	scheduler.RunArrivals(ctx, t.Executor, t.Iterations, deadline, rnd, func() bool {
+		select {
+		case u := <-idle:
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				u.Iterate(ctx, t, resultsChannel, s.actions)
+				idle <- u
+			}()
+			return true
+		default:
+			stats.AddDroppedIteration(1)
+			return false
+		}
+	})
+}
+
+// How often runStages re-evaluates the load profile.
+const stageTick = 100 * time.Millisecond
+
+// runStages walks through the stages of s, starting and retiring users and retuning
+// rl so the offered load follows the interpolated targets. Users are retired newest
+// first. Once the last stage is over or ctx is cancelled every remaining user is stopped.
+// rl is only retuned if a stage sets a rate, so scenarios without rates leave it alone.
+func runStages(ctx context.Context, s *scenario, resultsChannel chan result.HttpReqResult, rl *scheduler.RateLimiter, wg *sync.WaitGroup) {
+	defer wg.Done()
+	t := s.t
+	var active []*user.User
+	setsRate := false
+	for _, stage := range t.Stages {
+		setsRate = setsRate || stage.Rate != nil
+	}
+	initial := scheduler.Target{Users: t.Users, Rate: t.Rate}
+	start := time.Now()
+	tick := time.NewTicker(stageTick)
+	defer tick.Stop()
+	for {
+		target, running := scheduler.At(t.Stages, initial, time.Since(start))
+		if !running || ctx.Err() != nil {
+			break
+		}
+		if setsRate {
+			rl.SetRate(target.Rate)
+		}
+		for len(active) < target.Users {
+			active = append(active, startUser(ctx, s, resultsChannel, wg))
+		}
+		for len(active) > target.Users {
+			active[len(active)-1].Stop()
+			active = active[:len(active)-1]
+		}
+		select {
+		case <-tick.C:
+		case <-ctx.Done():
+		}
+	}
+	for _, u := range active {
+		u.Stop()
+	}
+}
+
+// sleep sleeps for d and reports whether it did so without ctx being cancelled.
+func sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
 	}
 }
 
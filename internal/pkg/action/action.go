@@ -23,8 +23,85 @@ SOFTWARE.
 */
 package action
 
-import "github.com/botcliq/loadzy/internal/pkg/result"
+import (
+	"context"
+	"fmt"
+	"time"
 
+	"github.com/botcliq/loadzy/internal/pkg/result"
+)
+
+// An Action is one step of an iteration, e.g. a http request. Execute must give up
+// when ctx is cancelled, which happens when the run is aborted. It returns an error
+// if the action failed; actions record their own failures in the stats, the error
+// is for the user running the action to decide how to go on.
 type Action interface {
-	Execute(resultsChannel chan result.HttpReqResult, sessionMap map[string]string)
+	Execute(ctx context.Context, env *Env) error
+}
+
+// Env is what an action is executed with.
+type Env struct {
+	// Scheduled is when the action was meant to start, so its coordinated-omission
+	// corrected response time also accounts for waiting on the rate limiter and a
+	// free worker. Zero means now.
+	Scheduled time.Time
+	// State is the state of the user running the action, nil if not run by a user.
+	State *UserState
+	// Results is the channel to write the results for the live dashboard to.
+	Results chan result.HttpReqResult
+	// Session holds the variables of the user's current iteration. Every user has its
+	// own and runs one action at a time, so actions use it without locking.
+	Session map[string]string
+	// Scenario is the name of the scenario of the user, empty if the test has none.
+	Scenario string
+}
+
+// report sends r to the live dashboard, if there is one.
+func (e *Env) report(r result.HttpReqResult) {
+	if e.Results != nil {
+		e.Results <- r
+	}
+}
+
+// setStatus records the status code of the last http response of the user, if any.
+func (e *Env) setStatus(status int) {
+	if e.State != nil {
+		e.State.setLastStatus(status)
+	}
+}
+
+func (e *Env) scheduled() time.Time {
+	if e.Scheduled.IsZero() {
+		return time.Now()
+	}
+	return e.Scheduled
+}
+
+// What a user does when an action fails, set per action with onError.
+const ON_ERROR_CONTINUE = "continue"
+const ON_ERROR_RETRY = "retry"
+const ON_ERROR_SKIP = "skip"
+const ON_ERROR_ABORT = "abort"
+
+// OnError tells the user running an action what to do when it fails: go on with
+// the next action, retry it up to Retries times, skip the rest of the iteration or
+// abort the whole run.
+type OnError struct {
+	Policy  string
+	Retries int
+}
+
+// Error is returned by an action that failed and has an onError policy.
+type Error struct {
+	Title   string
+	Err     error
+	OnError OnError
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %v", e.Title, e.Err)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
 }
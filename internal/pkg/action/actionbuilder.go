@@ -24,17 +24,26 @@ SOFTWARE.
 package action
 
 import (
+	"context"
+	"fmt"
 	"io/ioutil"
 	"log"
 	"os"
 
 	"github.com/botcliq/loadzy/internal/pkg/testdef"
+	"github.com/botcliq/loadzy/internal/pkg/util"
 )
 
 func BuildActionList(t *testdef.TestDef) ([]Action, bool) {
+	return buildActions(t.Actions, t)
+}
+
+// buildActions builds a list of actions, each element of which maps the kind of an
+// action to its definition.
+func buildActions(list []map[string]interface{}, t *testdef.TestDef) ([]Action, bool) {
 	var valid bool = true
-	actions := make([]Action, len(t.Actions), len(t.Actions))
-	for _, element := range t.Actions {
+	actions := make([]Action, 0, len(list))
+	for _, element := range list {
 		for key, value := range element {
 			var action Action
 			actionMap := value.(map[interface{}]interface{})
@@ -42,30 +51,77 @@ func BuildActionList(t *testdef.TestDef) ([]Action, bool) {
 			case "sleep":
 				action = NewSleepAction(actionMap)
 				break
-			case "http":
-				action = NewHttpAction(actionMap)
-				break
-			case "https":
-				action = NewHttpsAction(actionMap)
+			case "http", "https":
+				// https is kept as an alias, the URL scheme decides the protocol.
+				action = NewHttpAction(actionMap, t)
 				break
 			case "tcp":
 				action = NewTcpAction(actionMap)
 			case "udp":
 				action = NewUdpAction(actionMap)
 				break
+			case FLOW_GROUP, FLOW_IF, FLOW_LOOP, FLOW_WHILE:
+				// Flows apply their onError policy to their own errors only.
+				actions = append(actions, NewFlowAction(key, actionMap, t))
+				continue
 			default:
 				valid = false
 				log.Fatal("Unknown action type encountered: " + key)
 				break
 			}
+			onError, err := getOnError(actionMap)
+			if err != nil {
+				log.Printf("Error: %s action: %v\n", key, err)
+				valid = false
+			}
 			if valid {
-				actions = append(actions, action)
+				title, _ := actionMap["title"].(string)
+				actions = append(actions, policyAction{action, title, onError})
 			}
 		}
 	}
 	return actions, valid
 }
 
+// policyAction gives the errors of an action the onError policy it was defined with.
+type policyAction struct {
+	Action
+	title   string
+	onError OnError
+}
+
+func (p policyAction) Execute(ctx context.Context, env *Env) error {
+	if err := p.Action.Execute(ctx, env); err != nil {
+		return &Error{Title: p.title, Err: err, OnError: p.onError}
+	}
+	return nil
+}
+
+// getOnError parses what to do when the action fails: `onError` is one of continue
+// (the default), retry, skip or abort, and `retries` the number of retries (1 by default).
+func getOnError(action map[interface{}]interface{}) (OnError, error) {
+	onError := OnError{Policy: ON_ERROR_CONTINUE}
+	if v, ok := action["onError"]; ok {
+		onError.Policy = fmt.Sprint(v)
+	}
+	switch onError.Policy {
+	case ON_ERROR_CONTINUE, ON_ERROR_SKIP, ON_ERROR_ABORT:
+	case ON_ERROR_RETRY:
+		onError.Retries = 1
+	default:
+		return onError, fmt.Errorf("unknown onError '%s', must be %s, %s, %s or %s",
+			onError.Policy, ON_ERROR_CONTINUE, ON_ERROR_RETRY, ON_ERROR_SKIP, ON_ERROR_ABORT)
+	}
+	if v, ok := action["retries"]; ok {
+		n, ok := v.(int)
+		if !ok || n < 0 {
+			return onError, fmt.Errorf("retries must be > -1, was %v", v)
+		}
+		onError.Retries = n
+	}
+	return onError, nil
+}
+
 func getBody(action map[interface{}]interface{}) string {
 	//var body string = ""
 	if action["body"] != nil {
@@ -96,3 +152,16 @@ func getHeaders(action map[interface{}]interface{}) map[string]string {
 	}
 	return headers
 }
+
+// validTemplates logs the fields of an action that are not valid templates, and
+// reports whether all of them are.
+func validTemplates(kind string, fields map[string]string) bool {
+	valid := true
+	for name, text := range fields {
+		if _, err := util.ParseTemplate(text); err != nil {
+			log.Printf("Error: %s %s: %v\n", kind, name, err)
+			valid = false
+		}
+	}
+	return valid
+}
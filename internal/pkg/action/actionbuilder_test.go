@@ -0,0 +1,43 @@
+package action
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetOnError(t *testing.T) {
+	onError, err := getOnError(map[interface{}]interface{}{})
+	assert.NoError(t, err)
+	assert.Equal(t, OnError{Policy: ON_ERROR_CONTINUE}, onError)
+
+	onError, err = getOnError(map[interface{}]interface{}{"onError": "retry"})
+	assert.NoError(t, err)
+	assert.Equal(t, OnError{Policy: ON_ERROR_RETRY, Retries: 1}, onError)
+
+	onError, err = getOnError(map[interface{}]interface{}{"onError": "retry", "retries": 3})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, onError.Retries)
+
+	_, err = getOnError(map[interface{}]interface{}{"onError": "panic"})
+	assert.Error(t, err)
+	_, err = getOnError(map[interface{}]interface{}{"retries": -1})
+	assert.Error(t, err)
+}
+
+type failingAction struct{}
+
+func (failingAction) Execute(ctx context.Context, env *Env) error {
+	return errors.New("boom")
+}
+
+func TestPolicyAction_WrapsErrors(t *testing.T) {
+	a := policyAction{failingAction{}, "Login", OnError{Policy: ON_ERROR_SKIP}}
+	err := a.Execute(context.Background(), &Env{})
+	var failed *Error
+	assert.True(t, errors.As(err, &failed))
+	assert.Equal(t, ON_ERROR_SKIP, failed.OnError.Policy)
+	assert.Equal(t, "Login: boom", err.Error())
+}
@@ -0,0 +1,138 @@
+package action
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/botcliq/loadzy/internal/pkg/util"
+)
+
+// Comparison operators of a condition, the longer ones first so <= is not read as <.
+var conditionOps = []string{"==", "!=", "<=", ">=", "<", ">"}
+
+// A condition compares two operands with ==, !=, <, <=, > or >=, or is a single
+// operand that is true unless it is empty, false, 0, null, [] or {}. Operands are
+// templates, optionally quoted, e.g. ${cart.items | length} > 0 or ${next}.
+// Operands that are both numbers are compared as numbers, others as strings, with
+// null, which an extracted JSON null is stored as, equal to ""; <, <=, > and >= need
+// numbers.
+type condition struct {
+	text  string
+	left  string
+	op    string
+	right string
+}
+
+func parseCondition(text string) (*condition, error) {
+	c := &condition{text: text, left: text}
+	if i, op := findOperator(text); i >= 0 {
+		c.left, c.op, c.right = text[:i], op, text[i+len(op):]
+	}
+	c.left = operand(c.left)
+	c.right = operand(c.right)
+	if c.left == "" && c.op == "" {
+		return nil, fmt.Errorf("empty condition")
+	}
+	for _, o := range []string{c.left, c.right} {
+		if _, err := util.ParseTemplate(o); err != nil {
+			return nil, fmt.Errorf("condition %s: %v", text, err)
+		}
+	}
+	return c, nil
+}
+
+// findOperator returns the position of the first operator of text that is neither
+// inside a ${...} expression nor inside quotes, or -1 if there is none.
+func findOperator(text string) (int, string) {
+	var quote byte
+	depth := 0
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case strings.HasPrefix(text[i:], "${"):
+			depth++
+			i++
+		case depth > 0:
+			if c == '}' {
+				depth--
+			}
+		default:
+			for _, op := range conditionOps {
+				if strings.HasPrefix(text[i:], op) {
+					return i, op
+				}
+			}
+		}
+	}
+	return -1, ""
+}
+
+// operand trims s and the quotes around it.
+func operand(s string) string {
+	s = strings.TrimSpace(s)
+	if len(s) >= 2 && (s[0] == '\'' || s[0] == '"') && s[len(s)-1] == s[0] {
+		s = s[1 : len(s)-1]
+	}
+	return s
+}
+
+func (c *condition) String() string {
+	return c.text
+}
+
+// eval renders the operands with vars and compares them.
+func (c *condition) eval(vars map[string]string) (bool, error) {
+	left, err := util.Render(c.left, vars, util.EscapeNone)
+	if err != nil {
+		return false, fmt.Errorf("condition %s: %v", c, err)
+	}
+	if c.op == "" {
+		switch strings.TrimSpace(left) {
+		case "", "false", "0", "null", "[]", "{}":
+			return false, nil
+		}
+		return true, nil
+	}
+	right, err := util.Render(c.right, vars, util.EscapeNone)
+	if err != nil {
+		return false, fmt.Errorf("condition %s: %v", c, err)
+	}
+	left, right = notNull(left), notNull(right)
+	l, lerr := strconv.ParseFloat(strings.TrimSpace(left), 64)
+	r, rerr := strconv.ParseFloat(strings.TrimSpace(right), 64)
+	numbers := lerr == nil && rerr == nil
+	switch c.op {
+	case "==":
+		return numbers && l == r || !numbers && left == right, nil
+	case "!=":
+		return numbers && l != r || !numbers && left != right, nil
+	}
+	if !numbers {
+		return false, fmt.Errorf("condition %s: can not compare %q and %q, they must be numbers", c, left, right)
+	}
+	switch c.op {
+	case "<":
+		return l < r, nil
+	case "<=":
+		return l <= r, nil
+	case ">":
+		return l > r, nil
+	default:
+		return l >= r, nil
+	}
+}
+
+// notNull returns "" for null and s otherwise.
+func notNull(s string) string {
+	if strings.TrimSpace(s) == "null" {
+		return ""
+	}
+	return s
+}
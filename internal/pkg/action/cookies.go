@@ -0,0 +1,67 @@
+package action
+
+import (
+	"log"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+
+	"github.com/botcliq/loadzy/internal/pkg/testdef"
+	"github.com/botcliq/loadzy/internal/pkg/util"
+)
+
+func newJar() http.CookieJar {
+	// cookiejar.New only fails for options we don't use.
+	jar, _ := cookiejar.New(nil)
+	return jar
+}
+
+// jar returns the cookie jar of the user running the action, or nil if cookies are
+// disabled or the action is not run by a user.
+func (h HttpAction) jar(state *UserState) http.CookieJar {
+	if !h.cookies || state == nil {
+		return nil
+	}
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	return state.jar
+}
+
+// StartIteration prepares the user's cookie jar for a new iteration: it is emptied
+// if cfg says so, and seeded with the cookies of cfg, whose values may use the
+// variables of session.
+func (s *UserState) StartIteration(cfg testdef.Cookies, session map[string]string) {
+	if cfg.Disabled {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if cfg.ClearEachIteration {
+		s.jar = newJar()
+	}
+	for _, seed := range cfg.Seed {
+		u, cookie, err := seedCookie(seed, session)
+		if err != nil {
+			log.Printf("Error: cookie seed %s: %v\n", seed.Name, err)
+			continue
+		}
+		s.jar.SetCookies(u, []*http.Cookie{cookie})
+	}
+}
+
+// seedCookie returns the cookie seed sets and the URL to set it for.
+func seedCookie(seed testdef.CookieSeed, session map[string]string) (*url.URL, *http.Cookie, error) {
+	rawURL, err := util.Render(seed.Url, session, util.EscapeURL)
+	if err != nil {
+		return nil, nil, err
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, nil, err
+	}
+	value, err := util.Render(seed.Value, session, util.EscapeNone)
+	if err != nil {
+		return nil, nil, err
+	}
+	return u, &http.Cookie{Name: seed.Name, Value: value}, nil
+}
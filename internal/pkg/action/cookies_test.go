@@ -0,0 +1,60 @@
+package action
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/botcliq/loadzy/internal/pkg/testdef"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDoHttpRequest_KeepsCookiesPerUser(t *testing.T) {
+	var sent []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/login":
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "abc", Path: "/"})
+			http.SetCookie(w, &http.Cookie{Name: "admin", Value: "yes", Path: "/admin"})
+		case "/logout":
+			http.SetCookie(w, &http.Cookie{Name: "session", Path: "/", MaxAge: -1})
+		}
+		sent = append(sent, r.Header.Get("Cookie"))
+	}))
+	defer srv.Close()
+	newAction := func(path string) HttpAction {
+		return NewHttpAction(map[interface{}]interface{}{"title": path, "method": "GET", "url": srv.URL + path}, &testdef.TestDef{})
+	}
+
+	alice, bob := NewUserState(), NewUserState()
+	for _, step := range []struct {
+		user *UserState
+		path string
+	}{{alice, "/login"}, {alice, "/courses"}, {alice, "/admin/users"}, {bob, "/courses"}, {alice, "/logout"}, {alice, "/courses"}} {
+		assert.NoError(t, newAction(step.path).Execute(context.Background(), &Env{State: step.user}))
+	}
+	assert.Equal(t, []string{"", "session=abc", "admin=yes; session=abc", "", "session=abc", ""}, sent)
+
+	// Cookies are kept per host.
+	other := strings.Replace(srv.URL, "127.0.0.1", "localhost", 1)
+	sent = nil
+	action := NewHttpAction(map[interface{}]interface{}{"title": "other", "method": "GET", "url": other + "/courses"}, &testdef.TestDef{})
+	assert.NoError(t, newAction("/login").Execute(context.Background(), &Env{State: alice}))
+	assert.NoError(t, action.Execute(context.Background(), &Env{State: alice}))
+	assert.Equal(t, []string{"", ""}, sent)
+}
+
+func TestUserState_StartIteration(t *testing.T) {
+	s := NewUserState()
+	cfg := testdef.Cookies{Seed: []testdef.CookieSeed{{Name: "token", Value: "${token}", Url: "http://localhost/"}}}
+	s.StartIteration(cfg, map[string]string{"token": "t1"})
+	u, _ := url.Parse("http://localhost/courses")
+	assert.Equal(t, "t1", s.jar.Cookies(u)[0].Value)
+
+	s.jar.SetCookies(u, []*http.Cookie{{Name: "session", Value: "abc"}})
+	s.StartIteration(testdef.Cookies{ClearEachIteration: true}, nil)
+	assert.Empty(t, s.jar.Cookies(u))
+}
@@ -0,0 +1,269 @@
+package action
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// cssSelector is a comma separated group of CSS selectors, enough of CSS to find
+// elements in HTML forms and pages: type, #id, .class and [attribute] selectors with
+// the =, ~=, ^=, $= and *= operators, combined with descendant and > child combinators.
+type cssSelector [][]cssCompound
+
+// cssCompound matches a single element. combinator is how it relates to the compound
+// before it: ' ' for a descendant, '>' for a child.
+type cssCompound struct {
+	combinator byte
+	tag        string
+	id         string
+	classes    []string
+	attrs      []cssAttr
+}
+
+type cssAttr struct {
+	name, op, value string
+}
+
+func compileCSS(selector string) (cssSelector, error) {
+	var group cssSelector
+	for _, s := range strings.Split(selector, ",") {
+		compounds, err := parseCSS(strings.TrimSpace(s))
+		if err != nil {
+			return nil, fmt.Errorf("css %s: %v", selector, err)
+		}
+		group = append(group, compounds)
+	}
+	return group, nil
+}
+
+func parseCSS(s string) ([]cssCompound, error) {
+	if s == "" {
+		return nil, fmt.Errorf("empty selector")
+	}
+	var compounds []cssCompound
+	combinator := byte(' ')
+	for i := 0; i < len(s); {
+		switch c := s[i]; {
+		case c == ' ' || c == '\t':
+			i++
+			continue
+		case c == '>':
+			if len(compounds) == 0 || combinator == '>' {
+				return nil, fmt.Errorf("unexpected >")
+			}
+			combinator = '>'
+			i++
+			continue
+		}
+		compound, n, err := parseCompound(s[i:])
+		if err != nil {
+			return nil, err
+		}
+		compound.combinator = combinator
+		compounds = append(compounds, compound)
+		combinator = ' '
+		i += n
+	}
+	if combinator == '>' {
+		return nil, fmt.Errorf("selector ends in >")
+	}
+	return compounds, nil
+}
+
+// parseCompound parses the compound selector at the start of s, returning how much
+// of s it took.
+func parseCompound(s string) (cssCompound, int, error) {
+	var c cssCompound
+	i := 0
+	if i < len(s) && s[i] == '*' {
+		i++
+	} else {
+		n := cssIdent(s[i:])
+		c.tag = strings.ToLower(s[i : i+n])
+		i += n
+	}
+	for i < len(s) && s[i] != ' ' && s[i] != '\t' && s[i] != '>' {
+		switch s[i] {
+		case '#', '.':
+			n := cssIdent(s[i+1:])
+			if n == 0 {
+				return c, 0, fmt.Errorf("expected a name after %c", s[i])
+			}
+			if s[i] == '#' {
+				c.id = s[i+1 : i+1+n]
+			} else {
+				c.classes = append(c.classes, s[i+1:i+1+n])
+			}
+			i += 1 + n
+		case '[':
+			end := strings.IndexByte(s[i:], ']')
+			if end < 0 {
+				return c, 0, fmt.Errorf("unclosed [")
+			}
+			attr, err := parseCSSAttr(s[i+1 : i+end])
+			if err != nil {
+				return c, 0, err
+			}
+			c.attrs = append(c.attrs, attr)
+			i += end + 1
+		default:
+			return c, 0, fmt.Errorf("unexpected %q", s[i])
+		}
+	}
+	if i == 0 {
+		return c, 0, fmt.Errorf("unexpected %q", s[0])
+	}
+	return c, i, nil
+}
+
+func parseCSSAttr(s string) (cssAttr, error) {
+	n := cssIdent(s)
+	if n == 0 {
+		return cssAttr{}, fmt.Errorf("expected an attribute name in [%s]", s)
+	}
+	attr := cssAttr{name: strings.ToLower(s[:n])}
+	rest := strings.TrimSpace(s[n:])
+	if rest == "" {
+		return attr, nil
+	}
+	for _, op := range []string{"~=", "^=", "$=", "*=", "="} {
+		if strings.HasPrefix(rest, op) {
+			attr.op = op
+			attr.value = strings.Trim(strings.TrimSpace(rest[len(op):]), `"'`)
+			return attr, nil
+		}
+	}
+	return attr, fmt.Errorf("unknown attribute operator in [%s]", s)
+}
+
+func cssIdent(s string) int {
+	i := 0
+	for i < len(s) {
+		c := s[i]
+		if c == '-' || c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c >= 0x80 {
+			i++
+			continue
+		}
+		break
+	}
+	return i
+}
+
+// find returns the value of attribute of every element in body matching s, or their
+// text if attribute is empty.
+func (s cssSelector) find(body []byte, attribute string) ([]string, error) {
+	doc, err := html.Parse(bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	var values []string
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode && s.match(n) {
+			if attribute == "" {
+				values = append(values, strings.TrimSpace(nodeText(n)))
+			} else if v, ok := attr(n, attribute); ok {
+				values = append(values, v)
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+	return values, nil
+}
+
+func (s cssSelector) match(n *html.Node) bool {
+	for _, compounds := range s {
+		if matchCompounds(n, compounds) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchCompounds matches n against the last compound and its ancestors against the
+// ones before it.
+func matchCompounds(n *html.Node, compounds []cssCompound) bool {
+	last := compounds[len(compounds)-1]
+	if !last.match(n) {
+		return false
+	}
+	if len(compounds) == 1 {
+		return true
+	}
+	for p := n.Parent; p != nil && p.Type == html.ElementNode; p = p.Parent {
+		if matchCompounds(p, compounds[:len(compounds)-1]) {
+			return true
+		}
+		if last.combinator == '>' {
+			break
+		}
+	}
+	return false
+}
+
+func (c cssCompound) match(n *html.Node) bool {
+	if c.tag != "" && n.Data != c.tag {
+		return false
+	}
+	if c.id != "" {
+		if id, _ := attr(n, "id"); id != c.id {
+			return false
+		}
+	}
+	classes, _ := attr(n, "class")
+	for _, class := range c.classes {
+		if !containsField(classes, class) {
+			return false
+		}
+	}
+	for _, a := range c.attrs {
+		v, ok := attr(n, a.name)
+		if !ok {
+			return false
+		}
+		switch {
+		case a.op == "=" && v != a.value,
+			a.op == "~=" && !containsField(v, a.value),
+			a.op == "^=" && !strings.HasPrefix(v, a.value),
+			a.op == "$=" && !strings.HasSuffix(v, a.value),
+			a.op == "*=" && !strings.Contains(v, a.value):
+			return false
+		}
+	}
+	return true
+}
+
+func attr(n *html.Node, name string) (string, bool) {
+	for _, a := range n.Attr {
+		if a.Key == name {
+			return a.Val, true
+		}
+	}
+	return "", false
+}
+
+func containsField(s, field string) bool {
+	for _, f := range strings.Fields(s) {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+func nodeText(n *html.Node) string {
+	if n.Type == html.TextNode {
+		return n.Data
+	}
+	var sb strings.Builder
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		sb.WriteString(nodeText(c))
+	}
+	return sb.String()
+}
@@ -0,0 +1,287 @@
+package action
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/botcliq/loadzy/internal/pkg/testdef"
+	"github.com/botcliq/loadzy/internal/pkg/util"
+)
+
+// Kinds of flow actions.
+const FLOW_GROUP = "group"
+const FLOW_IF = "if"
+const FLOW_LOOP = "loop"
+const FLOW_WHILE = "while"
+
+// Used when a while action does not set maxIterations, so a condition that never
+// turns false can't keep a user busy forever.
+const DEFAULT_MAX_WHILE = 1000
+
+// A Flow is an action made of other actions: a group, if, loop or while. The user
+// running a flow calls Run, which decides which of its actions run and how often,
+// and hands them to run. run returns an error when the iteration must stop, e.g.
+// because an action failed with the skip policy; Run then returns that error as is.
+type Flow interface {
+	Action
+	Run(ctx context.Context, env *Env, run func(actions []Action) error) error
+}
+
+// FlowAction is a group, if, loop or while action. A group runs its actions once, an
+// if runs them when its condition holds and its else actions otherwise, a loop runs
+// them count times or once for every element of a JSON array, and a while runs them
+// as long as its condition holds, at most maxIterations times.
+//
+// Conditions can use the session variables and lastStatus, the status code of the
+// last http response of the user. The onError policy of a flow applies to its own
+// errors, like a condition with an unresolved variable; its actions have their own.
+type FlowAction struct {
+	Kind    string
+	Title   string
+	Actions []Action
+	Else    []Action
+	// Count is the number of times a loop runs, Over a JSON array it runs once for
+	// every element of. Both are templates.
+	Count string
+	Over  string
+	// Variable is the session variable holding the element of Over a loop is at, and
+	// Index the one holding the 0-based number of the iteration of a loop or while.
+	Variable      string
+	Index         string
+	MaxIterations int
+	OnError       OnError
+
+	condition *condition
+}
+
+// Run decides which actions of f run and how often.
+func (f FlowAction) Run(ctx context.Context, env *Env, run func(actions []Action) error) error {
+	switch f.Kind {
+	case FLOW_IF:
+		ok, err := f.condition.eval(conditionVars(env))
+		if err != nil {
+			return f.fail(err)
+		}
+		if ok {
+			return run(f.Actions)
+		}
+		return run(f.Else)
+	case FLOW_LOOP:
+		return f.loop(ctx, env, run)
+	case FLOW_WHILE:
+		for i := 0; ctx.Err() == nil; i++ {
+			ok, err := f.condition.eval(conditionVars(env))
+			if err != nil {
+				return f.fail(err)
+			}
+			if !ok {
+				return nil
+			}
+			if i == f.MaxIterations {
+				return f.fail(fmt.Errorf("condition %s still holds after %d iterations", f.condition, i))
+			}
+			if f.Index != "" {
+				env.Session[f.Index] = strconv.Itoa(i)
+			}
+			if err := run(f.Actions); err != nil {
+				return err
+			}
+		}
+		return ctx.Err()
+	}
+	return run(f.Actions)
+}
+
+func (f FlowAction) loop(ctx context.Context, env *Env, run func(actions []Action) error) error {
+	var items []interface{}
+	var n int
+	if f.Over != "" {
+		over, err := util.Render(f.Over, env.Session, util.EscapeNone)
+		if err != nil {
+			return f.fail(err)
+		}
+		if items, err = jsonArray(over); err != nil {
+			return f.fail(err)
+		}
+		n = len(items)
+	} else {
+		count, err := util.Render(f.Count, env.Session, util.EscapeNone)
+		if err != nil {
+			return f.fail(err)
+		}
+		if n, err = strconv.Atoi(strings.TrimSpace(count)); err != nil || n < 0 {
+			return f.fail(fmt.Errorf("count must be a number > -1, got %q", count))
+		}
+	}
+	for i := 0; i < n; i++ {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if items != nil {
+			item, err := sessionValue(items[i])
+			if err != nil {
+				return f.fail(err)
+			}
+			env.Session[f.Variable] = item
+		}
+		if f.Index != "" {
+			env.Session[f.Index] = strconv.Itoa(i)
+		}
+		if err := run(f.Actions); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Execute runs f on its own, executing its actions directly rather than through a
+// user. An action failing with the skip or abort policy stops it.
+func (f FlowAction) Execute(ctx context.Context, env *Env) error {
+	return f.Run(ctx, env, func(actions []Action) error {
+		for _, a := range actions {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			err := a.Execute(ctx, env)
+			var failed *Error
+			if errors.As(err, &failed) && (failed.OnError.Policy == ON_ERROR_SKIP || failed.OnError.Policy == ON_ERROR_ABORT) {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (f FlowAction) fail(err error) error {
+	return &Error{Title: f.Title, Err: err, OnError: f.OnError}
+}
+
+// conditionVars returns the variables conditions are evaluated with: the session
+// variables and lastStatus, unless the session has a variable of that name.
+func conditionVars(env *Env) map[string]string {
+	vars := make(map[string]string, len(env.Session)+1)
+	if env.State != nil {
+		vars["lastStatus"] = strconv.Itoa(env.State.LastStatus())
+	}
+	for k, v := range env.Session {
+		vars[k] = v
+	}
+	return vars
+}
+
+// jsonArray decodes the JSON array s; null is an empty one.
+func jsonArray(s string) ([]interface{}, error) {
+	var v interface{}
+	dec := json.NewDecoder(strings.NewReader(s))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		return nil, fmt.Errorf("over must be a JSON array: %v", err)
+	}
+	switch v := v.(type) {
+	case nil:
+		return []interface{}{}, nil
+	case []interface{}:
+		return v, nil
+	}
+	return nil, fmt.Errorf("over must be a JSON array, got %s", s)
+}
+
+// NewFlowAction builds the flow action of kind group, if, loop or while.
+func NewFlowAction(kind string, a map[interface{}]interface{}, t *testdef.TestDef) FlowAction {
+	valid := true
+	f := FlowAction{Kind: kind, Title: kind, Variable: "item", MaxIterations: DEFAULT_MAX_WHILE}
+	if title, ok := a["title"].(string); ok && title != "" {
+		f.Title = title
+	}
+
+	var ok bool
+	if f.Actions, ok = buildNestedActions(kind, a, "actions", t); !ok {
+		valid = false
+	} else if len(f.Actions) == 0 {
+		log.Printf("Error: %s action must define actions.\n", kind)
+		valid = false
+	}
+	if a["else"] != nil && kind != FLOW_IF {
+		log.Printf("Error: %s action can not define else, only an if action can.\n", kind)
+		valid = false
+	} else if f.Else, ok = buildNestedActions(kind, a, "else", t); !ok {
+		valid = false
+	}
+
+	switch kind {
+	case FLOW_IF, FLOW_WHILE:
+		text, _ := a["condition"].(string)
+		var err error
+		if f.condition, err = parseCondition(text); err != nil {
+			log.Printf("Error: %s action must define a valid condition: %v\n", kind, err)
+			valid = false
+		}
+	case FLOW_LOOP:
+		if a["count"] != nil {
+			f.Count = fmt.Sprint(a["count"])
+		}
+		f.Over, _ = a["over"].(string)
+		if (f.Count == "") == (f.Over == "") {
+			log.Println("Error: loop action must define either a count or an array to loop over.")
+			valid = false
+		}
+		if !validTemplates("loop", map[string]string{"count": f.Count, "over": f.Over}) {
+			valid = false
+		}
+		if v, ok := a["variable"].(string); ok && v != "" {
+			f.Variable = v
+		}
+	}
+	if kind == FLOW_LOOP || kind == FLOW_WHILE {
+		f.Index, _ = a["index"].(string)
+	}
+	if kind == FLOW_WHILE && a["maxIterations"] != nil {
+		n, ok := a["maxIterations"].(int)
+		if !ok || n <= 0 {
+			log.Printf("Error: while action maxIterations must be > 0, was %v\n", a["maxIterations"])
+			valid = false
+		}
+		f.MaxIterations = n
+	}
+
+	var err error
+	if f.OnError, err = getOnError(a); err != nil {
+		log.Printf("Error: %s action: %v\n", kind, err)
+		valid = false
+	}
+	if !valid {
+		log.Fatalf("Your YAML defintion contains an invalid %s action, see errors listed above.", kind)
+	}
+	return f
+}
+
+// buildNestedActions builds the list of actions under key of a flow action.
+func buildNestedActions(kind string, a map[interface{}]interface{}, key string, t *testdef.TestDef) ([]Action, bool) {
+	if a[key] == nil {
+		return nil, true
+	}
+	list, ok := a[key].([]interface{})
+	if !ok {
+		log.Printf("Error: %s action %s must be a list of actions.\n", kind, key)
+		return nil, false
+	}
+	elements := make([]map[string]interface{}, 0, len(list))
+	for _, item := range list {
+		m, ok := item.(map[interface{}]interface{})
+		if !ok {
+			log.Printf("Error: %s action %s must be a list of actions.\n", kind, key)
+			return nil, false
+		}
+		element := make(map[string]interface{}, len(m))
+		for k, v := range m {
+			element[fmt.Sprint(k)] = v
+		}
+		elements = append(elements, element)
+	}
+	return buildActions(elements, t)
+}
@@ -0,0 +1,145 @@
+package action
+
+import (
+	"context"
+	"testing"
+
+	"github.com/botcliq/loadzy/internal/pkg/testdef"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCondition_Eval(t *testing.T) {
+	vars := map[string]string{
+		"next":  "/page/2",
+		"empty": "",
+		"null":  "null",
+		"cart":  `{"items": [{"id": 1}, {"id": 2}]}`,
+		"name":  "a == b",
+		"total": "10",
+	}
+	for text, want := range map[string]bool{
+		"${cart.items | length} > 0":    true,
+		"${cart.items | length} >= 3":   false,
+		"${total} == 10.0":              true,
+		"${total} != '10'":              false,
+		"${next} != ''":                 true,
+		"${null} != ''":                 false,
+		"${null} == ''":                 true,
+		"${null}":                       false,
+		`${empty} == ""`:                true,
+		"${name} == 'a == b'":           true,
+		"${next}":                       true,
+		"${empty}":                      false,
+		"${cart.items[0].id} < 2":       true,
+		"${missing | default('0')}":     false,
+		"'${next}' == '/page/${total}'": false,
+	} {
+		c, err := parseCondition(text)
+		assert.NoError(t, err, text)
+		got, err := c.eval(vars)
+		assert.NoError(t, err, text)
+		assert.Equal(t, want, got, text)
+	}
+
+	c, _ := parseCondition("${next} > 1")
+	_, err := c.eval(vars)
+	assert.Error(t, err)
+	c, _ = parseCondition("${missing} == 1")
+	_, err = c.eval(vars)
+	assert.EqualError(t, err, "condition ${missing} == 1: unresolved variable missing")
+	_, err = parseCondition("")
+	assert.Error(t, err)
+	_, err = parseCondition("${next == 1")
+	assert.Error(t, err)
+}
+
+// recordAction appends the value of a session variable to steps.
+type recordAction struct {
+	variable string
+	steps    *[]string
+}
+
+func (r recordAction) Execute(ctx context.Context, env *Env) error {
+	*r.steps = append(*r.steps, env.Session[r.variable])
+	return nil
+}
+
+func TestFlowAction_Execute(t *testing.T) {
+	var steps []string
+	record := func(variable string) []Action { return []Action{recordAction{variable, &steps}} }
+	state := NewUserState()
+	state.setLastStatus(404)
+	env := &Env{State: state, Session: map[string]string{"items": `[{"id": 1}, "b"]`, "pages": "3"}}
+
+	for _, tc := range []struct {
+		flow FlowAction
+		want []string
+	}{
+		{FlowAction{Kind: FLOW_GROUP, Actions: record("pages")}, []string{"3"}},
+		{FlowAction{Kind: FLOW_LOOP, Count: "${pages}", Index: "i", Actions: record("i")}, []string{"0", "1", "2"}},
+		{FlowAction{Kind: FLOW_LOOP, Over: "${items}", Variable: "item", Actions: record("item")}, []string{`{"id":1}`, "b"}},
+		{FlowAction{Kind: FLOW_IF, condition: mustParseCondition("${lastStatus} == 404"), Actions: record("pages"), Else: record("items")}, []string{"3"}},
+		{FlowAction{Kind: FLOW_IF, condition: mustParseCondition("${pages} > 3"), Actions: record("pages"), Else: record("items")}, []string{`[{"id": 1}, "b"]`}},
+		{FlowAction{Kind: FLOW_WHILE, condition: mustParseCondition("${i | default('0')} != 2"), Index: "i", MaxIterations: 5, Actions: record("i")}, []string{"0", "1", "2"}},
+	} {
+		steps = nil
+		delete(env.Session, "i")
+		assert.NoError(t, tc.flow.Execute(context.Background(), env), tc.flow.Kind)
+		assert.Equal(t, tc.want, steps, tc.flow.Kind)
+	}
+
+	steps = nil
+	endless := FlowAction{Kind: FLOW_WHILE, Title: "Poll", condition: mustParseCondition("${pages} == 3"), MaxIterations: 2, Actions: record("pages"), OnError: OnError{Policy: ON_ERROR_SKIP}}
+	err := endless.Execute(context.Background(), env)
+	assert.EqualError(t, err, "Poll: condition ${pages} == 3 still holds after 2 iterations")
+	assert.Len(t, steps, 2)
+
+	notArray := FlowAction{Kind: FLOW_LOOP, Over: "${pages}", Actions: record("item")}
+	assert.Error(t, notArray.Execute(context.Background(), env))
+}
+
+func mustParseCondition(text string) *condition {
+	c, err := parseCondition(text)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+func TestBuildActionList_Flows(t *testing.T) {
+	td := &testdef.TestDef{Actions: []map[string]interface{}{
+		{"loop": map[interface{}]interface{}{
+			"over":     "${items}",
+			"variable": "item",
+			"actions": []interface{}{
+				map[interface{}]interface{}{"if": map[interface{}]interface{}{
+					"condition": "${item.stock} > 0",
+					"actions":   []interface{}{map[interface{}]interface{}{"sleep": map[interface{}]interface{}{"duration": "1ms"}}},
+					"else":      []interface{}{map[interface{}]interface{}{"sleep": map[interface{}]interface{}{"duration": "2ms"}}},
+				}},
+			},
+		}},
+		{"while": map[interface{}]interface{}{
+			"title":         "Paginate",
+			"condition":     "${next}",
+			"maxIterations": 20,
+			"onError":       "skip",
+			"actions":       []interface{}{map[interface{}]interface{}{"sleep": map[interface{}]interface{}{"duration": "1ms"}}},
+		}},
+	}}
+	actions, ok := BuildActionList(td)
+	assert.True(t, ok)
+	assert.Len(t, actions, 2)
+
+	loop := actions[0].(FlowAction)
+	assert.Equal(t, "${items}", loop.Over)
+	assert.Len(t, loop.Actions, 1)
+	cond := loop.Actions[0].(FlowAction)
+	assert.Len(t, cond.Actions, 1)
+	assert.Len(t, cond.Else, 1)
+
+	while := actions[1].(FlowAction)
+	assert.Equal(t, "Paginate", while.Title)
+	assert.Equal(t, 20, while.MaxIterations)
+	assert.Equal(t, ON_ERROR_SKIP, while.OnError.Policy)
+}
@@ -24,43 +24,60 @@ SOFTWARE.
 package action
 
 import (
+	"context"
 	"log"
+	"net/http"
+	"strings"
+	"time"
+	"unicode"
 
-	"github.com/botcliq/loadzy/internal/pkg/result"
+	"github.com/botcliq/loadzy/internal/pkg/testdef"
 )
 
 type HttpAction struct {
-	Method          string              `yaml:"method"`
-	Url             string              `yaml:"url"`
-	Body            string              `yaml:"body"`
-	Template        string              `yaml:"template"`
-	Accept          string              `yaml:"accept"`
-	ContentType     string              `yaml:"contentType"`
-	Title           string              `yaml:"title"`
-	ResponseHandler HttpResponseHandler `yaml:"response"`
-	StoreCookie     string              `yaml:"storeCookie"`
-	Headers         map[string]string   `yaml:"headers"`
+	Method      string            `yaml:"method"`
+	Url         string            `yaml:"url"`
+	Body        string            `yaml:"body"`
+	Template    string            `yaml:"template"`
+	Accept      string            `yaml:"accept"`
+	ContentType string            `yaml:"contentType"`
+	Title       string            `yaml:"title"`
+	Extractors  []HttpExtractor   `yaml:"response"`
+	Headers     map[string]string `yaml:"headers"`
+	Checks      []HttpCheck       `yaml:"checks"`
+	// Timeout bounds the whole request, from connecting until the body is read.
+	Timeout         time.Duration     `yaml:"timeout"`
+	FollowRedirects testdef.Redirects `yaml:"followRedirects"`
+
+	transport *http.Transport
+	perUser   bool
+	cookies   bool
 }
 
-func (h HttpAction) Execute(resultsChannel chan result.HttpReqResult, sessionMap map[string]string) {
-	DoHttpRequest(h, resultsChannel, sessionMap)
+func (h HttpAction) Execute(ctx context.Context, env *Env) error {
+	return DoHttpRequest(ctx, h, env)
 }
 
-type HttpResponseHandler struct {
-	Jsonpath string `yaml:"jsonpath"`
-	Xmlpath  string `yaml:"xmlpath"`
-	Variable string `yaml:"variable"`
-	Index    string `yaml:"index"`
+// roundTripper returns the connection pool to send the request with: the user's own
+// one if users don't share connections, else the shared one.
+func (h HttpAction) roundTripper(state *UserState) http.RoundTripper {
+	if h.perUser && state != nil {
+		return state.transportFor(h.transport)
+	}
+	return h.transport
 }
 
-func NewHttpAction(a map[interface{}]interface{}) HttpAction {
+// NewHttpAction builds a HttpAction for both http and https URLs; the scheme of the
+// URL decides which one is used.
+func NewHttpAction(a map[interface{}]interface{}, t *testdef.TestDef) HttpAction {
 	valid := true
 	if a["url"] == "" || a["url"] == nil {
 		log.Println("Error: HttpAction must define a URL.")
 		valid = false
 	}
-	if a["method"] == nil || (a["method"] != "GET" && a["method"] != "POST" && a["method"] != "PUT" && a["method"] != "DELETE") {
-		log.Println("Error: HttpAction must specify a HTTP method: GET, POST, PUT or DELETE")
+	method, _ := a["method"].(string)
+	if !validMethod(method) {
+		log.Println("Error: HttpAction must specify a HTTP method, e.g. GET, POST, PUT, PATCH, DELETE, HEAD, OPTIONS or a custom one like PURGE")
 		valid = false
 	}
 	if a["title"] == nil || a["title"] == "" {
@@ -72,48 +89,61 @@ func NewHttpAction(a map[interface{}]interface{}) HttpAction {
 		log.Println("Error: A HttpAction can not define both a 'body' and a 'template'.")
 		valid = false
 	}
+	if method == http.MethodHead && (a["body"] != nil || a["template"] != nil) {
+		log.Println("Error: A HEAD HttpAction can not define a 'body' or a 'template'.")
+		valid = false
+	}
 
-	if a["response"] != nil {
-		r := a["response"].(map[interface{}]interface{})
-		if r["index"] == nil || r["index"] == "" || (r["index"] != "first" && r["index"] != "last" && r["index"] != "random") {
-			log.Println("Error: HttpAction ResponseHandler must define an Index of either of: first, last or random.")
-			valid = false
-		}
-		if (r["jsonpath"] == nil || r["jsonpath"] == "") && (r["xmlpath"] == nil || r["xmlpath"] == "") {
-			log.Println("Error: HttpAction ResponseHandler must define a Jsonpath or a Xmlpath.")
-			valid = false
-		}
-		if (r["jsonpath"] != nil && r["jsonpath"] != "") && (r["xmlpath"] != nil && r["xmlpath"] != "") {
-			log.Println("Error: HttpAction ResponseHandler can only define either a Jsonpath OR a Xmlpath.")
-			valid = false
-		}
-
-		// TODO perhaps compile Xmlpath expressions so we can validate early?
+	extractors, extractorsValid := getExtractors(a)
+	if !extractorsValid {
+		valid = false
+	}
 
-		if r["variable"] == nil || r["variable"] == "" {
-			log.Println("Error: HttpAction ResponseHandler must define a Variable.")
-			valid = false
+	fields := map[string]string{}
+	for _, key := range []string{"url", "body", "accept", "contentType"} {
+		if s, ok := a[key].(string); ok {
+			fields[key] = s
 		}
 	}
-
-	if !valid {
-		log.Fatalf("Your YAML defintion contains an invalid HttpAction, see errors listed above.")
+	for key, value := range getHeaders(a) {
+		fields["header "+key] = value
+	}
+	if !validTemplates("HttpAction", fields) {
+		valid = false
 	}
-	var responseHandler HttpResponseHandler
-	if a["response"] != nil {
-		response := a["response"].(map[interface{}]interface{})
 
-		if response["jsonpath"] != nil && response["jsonpath"] != "" {
-			responseHandler.Jsonpath = response["jsonpath"].(string)
-		}
-		if response["xmlpath"] != nil && response["xmlpath"] != "" {
-			responseHandler.Xmlpath = response["xmlpath"].(string)
-		}
+	checks, checksValid := getChecks(a)
+	if !checksValid {
+		valid = false
+	}
 
-		responseHandler.Variable = response["variable"].(string)
-		responseHandler.Index = response["index"].(string)
+	tlsCfg, err := getTLS(a, t.TLS)
+	if err != nil {
+		log.Printf("Error: HttpAction has an invalid tls block: %v\n", err)
+		valid = false
+	}
+	httpCfg := t.Http
+	if httpCfg.Timeouts, err = getTimeouts(a, t.Http.Timeouts); err != nil {
+		log.Printf("Error: HttpAction has invalid timeouts: %v\n", err)
+		valid = false
+	}
+	redirects, err := getRedirects(a, t.Http.FollowRedirects)
+	if err != nil {
+		log.Printf("Error: HttpAction has an invalid followRedirects: %v\n", err)
+		valid = false
+	} else if redirects.Max < 0 {
+		log.Println("Error: HttpAction followRedirects must be > -1")
+		valid = false
+	}
+	transport, err := sharedTransport(httpCfg, tlsCfg)
+	if err != nil {
+		log.Printf("Error: HttpAction TLS settings: %v\n", err)
+		valid = false
 	}
 
+	if !valid {
+		log.Fatalf("Your YAML defintion contains an invalid HttpAction, see errors listed above.")
+	}
 	accept := "text/html,application/json,application/xhtml+xml,application/xml,text/plain"
 	if a["accept"] != nil && len(a["accept"].(string)) > 0 {
 		accept = a["accept"].(string)
@@ -124,23 +154,41 @@ func NewHttpAction(a map[interface{}]interface{}) HttpAction {
 		contentType = a["contentType"].(string)
 	}
 
-	var storeCookie string
-	if a["storeCookie"] != nil && a["storeCookie"].(string) != "" {
-		storeCookie = a["storeCookie"].(string)
+	if a["storeCookie"] != nil {
+		log.Printf("Warning: HttpAction '%s': storeCookie is no longer used, every user keeps all its cookies unless http cookies are disabled.\n", a["title"])
 	}
 
 	httpAction := HttpAction{
-		a["method"].(string),
+		method,
 		a["url"].(string),
 		getBody(a),
 		getTemplate(a),
 		accept,
 		contentType,
 		a["title"].(string),
-		responseHandler,
-		storeCookie,
+		extractors,
 		getHeaders(a),
+		checks,
+		requestTimeout(httpCfg.Timeouts),
+		redirects,
+		transport,
+		t.Http.ConnectionPool == testdef.PER_USER_POOL,
+		!t.Http.Cookies.Disabled,
 	}
 
 	return httpAction
 }
+
+// validMethod returns whether method is a valid HTTP method token. Besides the
+// standard methods any custom one is accepted, e.g. PURGE or PROPFIND.
+func validMethod(method string) bool {
+	if method == "" {
+		return false
+	}
+	for _, c := range method {
+		if c > unicode.MaxASCII || unicode.IsSpace(c) || unicode.IsControl(c) || strings.ContainsRune(`"(),/:;<=>?@[\]{}`, c) {
+			return false
+		}
+	}
+	return true
+}
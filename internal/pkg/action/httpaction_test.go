@@ -0,0 +1,83 @@
+package action
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/botcliq/loadzy/internal/pkg/stats"
+	"github.com/botcliq/loadzy/internal/pkg/testdef"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidMethod(t *testing.T) {
+	for _, m := range []string{"GET", "PATCH", "HEAD", "OPTIONS", "PURGE", "PROPFIND", "M-SEARCH"} {
+		assert.True(t, validMethod(m), m)
+	}
+	for _, m := range []string{"", "GET /", "PUR\tGE", "GET;", "GÉT"} {
+		assert.False(t, validMethod(m), m)
+	}
+}
+
+func TestDoHttpRequest_Methods(t *testing.T) {
+	var got []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		got = append(got, r.Method+" "+string(body))
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	for _, method := range []string{"PATCH", "HEAD", "OPTIONS", "PURGE", "PROPFIND"} {
+		a := map[interface{}]interface{}{"title": method, "method": method, "url": srv.URL,
+			"checks": []interface{}{map[interface{}]interface{}{"status": 200}}}
+		if method != "HEAD" {
+			a["body"] = "data"
+		}
+		assert.NoError(t, NewHttpAction(a, &testdef.TestDef{}).Execute(context.Background(), &Env{}), method)
+	}
+	assert.Equal(t, []string{"PATCH data", "HEAD ", "OPTIONS data", "PURGE data", "PROPFIND data"}, got)
+}
+
+func TestDoHttpRequest_RendersAllFields(t *testing.T) {
+	var got http.Header
+	var host string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, host = r.Header, r.Host
+		w.Write([]byte(`{"owner": "Erik"}`))
+	}))
+	defer srv.Close()
+	a := map[interface{}]interface{}{"title": "all", "method": "GET", "url": srv.URL + "/${id}",
+		"accept": "application/${format}", "contentType": "text/${format}",
+		"headers": map[interface{}]interface{}{"Authorization": "Bearer ${token}", "Host": "${vhost}"},
+		"checks": []interface{}{
+			map[interface{}]interface{}{"bodyContains": "${owner}"},
+			map[interface{}]interface{}{"jsonpath": "$.owner", "equals": "${owner}"},
+			map[interface{}]interface{}{"bodyMatches": `"owner": "${owner}"`},
+		}}
+	action := NewHttpAction(a, &testdef.TestDef{})
+	stats.ClearOrAddMetrics(1)
+	vars := map[string]string{"id": "1", "format": "json", "token": "secret", "vhost": "example.com", "owner": "Erik"}
+
+	assert.NoError(t, action.Execute(context.Background(), &Env{Session: vars}))
+	assert.Equal(t, "application/json", got.Get("Accept"))
+	assert.Equal(t, "text/json", got.Get("Content-Type"))
+	assert.Equal(t, "Bearer secret", got.Get("Authorization"))
+	assert.Equal(t, "example.com", host)
+	for name, c := range stats.GetMetric(1).Checks {
+		assert.Equal(t, uint64(0), c.Fails, name)
+	}
+
+	delete(vars, "token")
+	got = nil
+	err := action.Execute(context.Background(), &Env{Session: vars})
+	assert.EqualError(t, err, "header Authorization: unresolved variable token")
+	assert.Nil(t, got, "nothing is sent")
+
+	vars["token"] = "secret"
+	delete(vars, "owner")
+	err = action.Execute(context.Background(), &Env{Session: vars})
+	assert.EqualError(t, err, "check body contains '${owner}': unresolved variable owner")
+}
@@ -0,0 +1,230 @@
+package action
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/botcliq/loadzy/internal/pkg/stats"
+	"github.com/botcliq/loadzy/internal/pkg/util"
+	"github.com/oliveagle/jsonpath"
+)
+
+// HttpCheck is an assertion on the response of a HttpAction. Each check verifies
+// exactly one thing, e.g. the status code or a JSONPath value; its Name describes
+// what and is used to count its passes and failures in stats.Metrics.
+type HttpCheck struct {
+	Name         string
+	Status       []int
+	BodyContains string
+	BodyMatches  *regexp.Regexp
+	Jsonpath     *jsonpath.Compiled
+	Equals       interface{}
+	Header       string
+	MaxLatency   time.Duration
+
+	// bodyMatches is the pattern of BodyMatches if it uses variables, so it is only
+	// compiled once they are resolved.
+	bodyMatches string
+}
+
+// Verify reports whether the check holds for resp. A nil resp (the request failed)
+// never passes.
+func (c HttpCheck) Verify(resp *http.Response, body []byte, latency time.Duration) bool {
+	if resp == nil {
+		return false
+	}
+	switch {
+	case c.Status != nil:
+		for _, s := range c.Status {
+			if resp.StatusCode == s {
+				return true
+			}
+		}
+		return false
+	case c.BodyContains != "":
+		return bytes.Contains(body, []byte(c.BodyContains))
+	case c.BodyMatches != nil:
+		return c.BodyMatches.Match(body)
+	case c.bodyMatches != "":
+		return false
+	case c.Jsonpath != nil:
+		var jsonData interface{}
+		if err := json.Unmarshal(body, &jsonData); err != nil {
+			return false
+		}
+		res, err := c.Jsonpath.Lookup(jsonData)
+		if err != nil {
+			return false
+		}
+		return fmt.Sprint(res) == fmt.Sprint(c.Equals)
+	case c.Header != "":
+		return resp.Header.Get(c.Header) != ""
+	case c.MaxLatency > 0:
+		return latency <= c.MaxLatency
+	}
+	return true
+}
+
+// resolve returns the check with the variables in its expectations replaced by their
+// values in vars.
+func (c HttpCheck) resolve(vars map[string]string) (HttpCheck, error) {
+	var err error
+	if c.BodyContains, err = util.Render(c.BodyContains, vars, util.EscapeNone); err != nil {
+		return c, err
+	}
+	if c.Header, err = util.Render(c.Header, vars, util.EscapeNone); err != nil {
+		return c, err
+	}
+	if equals, ok := c.Equals.(string); ok {
+		if c.Equals, err = util.Render(equals, vars, util.EscapeNone); err != nil {
+			return c, err
+		}
+	}
+	if c.bodyMatches != "" {
+		pattern, err := util.Render(c.bodyMatches, vars, util.EscapeNone)
+		if err != nil {
+			return c, err
+		}
+		if c.BodyMatches, err = regexp.Compile(pattern); err != nil {
+			return c, err
+		}
+	}
+	return c, nil
+}
+
+// verifyChecks runs all checks against a response and records the outcome of each
+// one under the title of the action it belongs to. A check whose expectation can't
+// be resolved with vars fails, and the first such error is returned.
+func verifyChecks(title string, checks []HttpCheck, vars map[string]string, resp *http.Response, body []byte, latency time.Duration) error {
+	var failed error
+	for _, c := range checks {
+		resolved, err := c.resolve(vars)
+		if err != nil {
+			stats.AddCheck(1, title+": "+c.Name, false)
+			if failed == nil {
+				failed = fmt.Errorf("check %s: %v", c.Name, err)
+			}
+			continue
+		}
+		stats.AddCheck(1, title+": "+c.Name, resolved.Verify(resp, body, latency))
+	}
+	return failed
+}
+
+// getChecks parses the `checks` list of an http action. Every entry must define
+// exactly one of: status, bodyContains, bodyMatches, jsonpath (with equals), header
+// or maxLatency.
+func getChecks(action map[interface{}]interface{}) ([]HttpCheck, bool) {
+	if action["checks"] == nil {
+		return nil, true
+	}
+	list, ok := action["checks"].([]interface{})
+	if !ok {
+		log.Println("Error: HttpAction checks must be a list.")
+		return nil, false
+	}
+	valid := true
+	checks := make([]HttpCheck, 0, len(list))
+	for i, item := range list {
+		c, ok := item.(map[interface{}]interface{})
+		if !ok {
+			log.Printf("Error: HttpAction check %d must be a map.\n", i+1)
+			valid = false
+			continue
+		}
+		check, err := newHttpCheck(c)
+		if err != nil {
+			log.Printf("Error: HttpAction check %d: %v\n", i+1, err)
+			valid = false
+			continue
+		}
+		checks = append(checks, check)
+	}
+	return checks, valid
+}
+
+func newHttpCheck(c map[interface{}]interface{}) (HttpCheck, error) {
+	var check HttpCheck
+	kinds := 0
+	if v, ok := c["status"]; ok {
+		kinds++
+		switch s := v.(type) {
+		case int:
+			check.Status = []int{s}
+		case []interface{}:
+			for _, code := range s {
+				n, ok := code.(int)
+				if !ok {
+					return check, fmt.Errorf("status must be a list of status codes, got %v", code)
+				}
+				check.Status = append(check.Status, n)
+			}
+		default:
+			return check, fmt.Errorf("status must be a status code or a list of them, got %T", v)
+		}
+		check.Name = fmt.Sprintf("status in %v", check.Status)
+	}
+	if v, ok := c["bodyContains"]; ok {
+		kinds++
+		check.BodyContains = fmt.Sprint(v)
+		check.Name = fmt.Sprintf("body contains '%s'", check.BodyContains)
+	}
+	if v, ok := c["bodyMatches"]; ok {
+		kinds++
+		pattern := fmt.Sprint(v)
+		if strings.Contains(pattern, "${") {
+			if _, err := util.ParseTemplate(pattern); err != nil {
+				return check, err
+			}
+			check.bodyMatches = pattern
+		} else {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return check, err
+			}
+			check.BodyMatches = re
+		}
+		check.Name = fmt.Sprintf("body matches '%s'", pattern)
+	}
+	if v, ok := c["jsonpath"]; ok {
+		kinds++
+		if _, ok := c["equals"]; !ok {
+			return check, fmt.Errorf("jsonpath check must define equals")
+		}
+		p, err := jsonpath.Compile(fmt.Sprint(v))
+		if err != nil {
+			return check, err
+		}
+		check.Jsonpath = p
+		check.Equals = c["equals"]
+		check.Name = fmt.Sprintf("%s == %v", v, check.Equals)
+	}
+	if v, ok := c["header"]; ok {
+		kinds++
+		check.Header = fmt.Sprint(v)
+		check.Name = fmt.Sprintf("header %s present", check.Header)
+	}
+	if v, ok := c["maxLatency"]; ok {
+		kinds++
+		if secs, ok := v.(int); ok {
+			check.MaxLatency = time.Second * time.Duration(secs)
+		} else {
+			d, err := time.ParseDuration(fmt.Sprint(v))
+			if err != nil {
+				return check, err
+			}
+			check.MaxLatency = d
+		}
+		check.Name = fmt.Sprintf("latency <= %s", check.MaxLatency)
+	}
+	if kinds != 1 {
+		return check, fmt.Errorf("must define exactly one of status, bodyContains, bodyMatches, jsonpath, header or maxLatency")
+	}
+	return check, nil
+}
@@ -0,0 +1,45 @@
+package action
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetChecks_VerifyResponse(t *testing.T) {
+	checks, valid := getChecks(map[interface{}]interface{}{"checks": []interface{}{
+		map[interface{}]interface{}{"status": []interface{}{200, 201}},
+		map[interface{}]interface{}{"bodyContains": "ok"},
+		map[interface{}]interface{}{"bodyMatches": `"id":\d+`},
+		map[interface{}]interface{}{"jsonpath": "$.count", "equals": 2},
+		map[interface{}]interface{}{"header": "X-Request-ID"},
+		map[interface{}]interface{}{"maxLatency": "300ms"},
+	}})
+	assert.True(t, valid)
+	assert.Len(t, checks, 6)
+
+	resp := &http.Response{StatusCode: 201, Header: http.Header{"X-Request-Id": []string{"abc"}}}
+	body := []byte(`{"id":12,"count":2,"status":"ok"}`)
+	for _, c := range checks {
+		assert.True(t, c.Verify(resp, body, 100*time.Millisecond), c.Name)
+	}
+
+	resp = &http.Response{StatusCode: 500, Header: http.Header{}}
+	for _, c := range checks {
+		assert.False(t, c.Verify(resp, []byte(`{"count":3}`), time.Second), c.Name)
+	}
+}
+
+func TestGetChecks_RejectsInvalidChecks(t *testing.T) {
+	_, valid := getChecks(map[interface{}]interface{}{"checks": []interface{}{
+		map[interface{}]interface{}{"status": 200, "header": "X-Request-ID"},
+	}})
+	assert.False(t, valid)
+
+	_, valid = getChecks(map[interface{}]interface{}{"checks": []interface{}{
+		map[interface{}]interface{}{"jsonpath": "$.count"},
+	}})
+	assert.False(t, valid)
+}
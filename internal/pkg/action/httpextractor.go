@@ -0,0 +1,360 @@
+package action
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/rand"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/botcliq/loadzy/internal/pkg/testdef"
+	"github.com/oliveagle/jsonpath"
+	"gopkg.in/xmlpath.v2"
+	"gopkg.in/yaml.v2"
+)
+
+// Where an extractor takes its value from.
+const (
+	SOURCE_BODY   = "body"
+	SOURCE_HEADER = "header"
+	SOURCE_COOKIE = "cookie"
+	SOURCE_STATUS = "status"
+)
+
+// What an extractor does when it finds nothing.
+const (
+	ON_MISSING_ERROR   = "error"
+	ON_MISSING_DEFAULT = "default"
+	ON_MISSING_SKIP    = "skip"
+)
+
+// HttpExtractor takes a value out of the response of a HttpAction and stores it in a
+// session variable. Its source is the body (the default), a header, a cookie or the
+// status code. Values of the body are found with a jsonpath, xmlpath or css selector;
+// the values of any source can be narrowed down with a regex, whose first capture
+// group is taken if it has one, or with the left and right boundaries around them.
+// When there are several matches, index picks the first, last or a random one, or all
+// to store them as a JSON array.
+//
+// Values found with a jsonpath keep their type: numbers and booleans are stored as
+// their JSON text and arrays and objects as JSON documents, so later actions can index
+// them (${items[0].id}), loop over them or embed them in a body with ${items | raw}.
+type HttpExtractor struct {
+	Source   string `yaml:"source"`
+	Name     string `yaml:"name"`
+	Jsonpath string `yaml:"jsonpath"`
+	Xmlpath  string `yaml:"xmlpath"`
+	Css      string `yaml:"css"`
+	// Attribute is the attribute of the elements found with Css to take, e.g. value
+	// for input fields; without it their text is taken.
+	Attribute string `yaml:"attribute"`
+	Regex     string `yaml:"regex"`
+	Left      string `yaml:"left"`
+	Right     string `yaml:"right"`
+	Variable  string `yaml:"variable"`
+	Index     string `yaml:"index"`
+	// OnMissing is error (the default) to fail the action when nothing is found,
+	// default to store Default instead, or skip to leave the variable unset.
+	OnMissing string `yaml:"onMissing"`
+	Default   string `yaml:"default"`
+
+	jsonpath *jsonpath.Compiled
+	xmlpath  *xmlpath.Path
+	css      cssSelector
+	regex    *regexp.Regexp
+}
+
+// getExtractors parses the `response` of an http action: one extractor or a list of them.
+func getExtractors(action map[interface{}]interface{}) ([]HttpExtractor, bool) {
+	var list []interface{}
+	switch r := action["response"].(type) {
+	case nil:
+		return nil, true
+	case []interface{}:
+		list = r
+	case map[interface{}]interface{}:
+		list = []interface{}{r}
+	default:
+		log.Println("Error: HttpAction response must be an extractor or a list of them.")
+		return nil, false
+	}
+	valid := true
+	extractors := make([]HttpExtractor, 0, len(list))
+	for i, item := range list {
+		e, err := newHttpExtractor(item)
+		if err != nil {
+			log.Printf("Error: HttpAction response %d: %v\n", i+1, err)
+			valid = false
+			continue
+		}
+		extractors = append(extractors, e)
+	}
+	return extractors, valid
+}
+
+func newHttpExtractor(item interface{}) (HttpExtractor, error) {
+	var e HttpExtractor
+	raw, err := yaml.Marshal(item)
+	if err != nil {
+		return e, err
+	}
+	if err := yaml.UnmarshalStrict(raw, &e); err != nil {
+		return e, err
+	}
+	if e.Variable == "" {
+		return e, fmt.Errorf("must define a variable")
+	}
+	if e.Index == "" {
+		e.Index = testdef.FIRST
+	}
+	if e.Index != testdef.FIRST && e.Index != testdef.LAST && e.Index != testdef.RANDOM && e.Index != testdef.ALL {
+		return e, fmt.Errorf("index must be first, last, random or all, got %s", e.Index)
+	}
+	if e.OnMissing == "" {
+		e.OnMissing = ON_MISSING_ERROR
+	}
+	if e.OnMissing != ON_MISSING_ERROR && e.OnMissing != ON_MISSING_DEFAULT && e.OnMissing != ON_MISSING_SKIP {
+		return e, fmt.Errorf("onMissing must be error, default or skip, got %s", e.OnMissing)
+	}
+	if e.Source == "" {
+		e.Source = SOURCE_BODY
+	}
+	expressions := 0
+	for _, expr := range []string{e.Jsonpath, e.Xmlpath, e.Css, e.Regex, e.Left + e.Right} {
+		if expr != "" {
+			expressions++
+		}
+	}
+	if expressions > 1 {
+		return e, fmt.Errorf("can only define one of jsonpath, xmlpath, css, regex or left and right")
+	}
+	if e.Attribute != "" && e.Css == "" {
+		return e, fmt.Errorf("attribute needs a css selector")
+	}
+	if e.Regex != "" {
+		if e.regex, err = regexp.Compile(e.Regex); err != nil {
+			return e, fmt.Errorf("regex %s: %v", e.Regex, err)
+		}
+	}
+	if e.Source != SOURCE_BODY && (e.Jsonpath != "" || e.Xmlpath != "" || e.Css != "") {
+		return e, fmt.Errorf("jsonpath, xmlpath and css only apply to the body")
+	}
+	switch e.Source {
+	case SOURCE_BODY:
+		if e.Jsonpath != "" {
+			if e.jsonpath, err = jsonpath.Compile(e.Jsonpath); err != nil {
+				return e, fmt.Errorf("jsonpath %s: %v", e.Jsonpath, err)
+			}
+		}
+		if e.Xmlpath != "" {
+			if e.xmlpath, err = xmlpath.Compile(e.Xmlpath); err != nil {
+				return e, fmt.Errorf("xmlpath %s: %v", e.Xmlpath, err)
+			}
+		}
+		if e.Css != "" {
+			if e.css, err = compileCSS(e.Css); err != nil {
+				return e, err
+			}
+		}
+	case SOURCE_HEADER, SOURCE_COOKIE:
+		if e.Name == "" {
+			return e, fmt.Errorf("%s source must define the name of the %s", e.Source, e.Source)
+		}
+	case SOURCE_STATUS:
+	default:
+		return e, fmt.Errorf("unknown source %s, must be body, header, cookie or status", e.Source)
+	}
+	return e, nil
+}
+
+func (e HttpExtractor) String() string {
+	switch {
+	case e.Jsonpath != "":
+		return "jsonpath " + e.Jsonpath
+	case e.Xmlpath != "":
+		return "xmlpath " + e.Xmlpath
+	case e.Css != "":
+		return "css " + e.Css
+	case e.Regex != "":
+		return "regex " + e.Regex
+	case e.Left != "" || e.Right != "":
+		return fmt.Sprintf("boundary %q...%q", e.Left, e.Right)
+	case e.Name != "":
+		return e.Source + " " + e.Name
+	}
+	return e.Source
+}
+
+// Extract stores the value e finds in resp and its body into vars.
+func (e HttpExtractor) Extract(resp *http.Response, body []byte, vars map[string]string) error {
+	values, err := e.find(resp, body)
+	if err != nil && e.OnMissing == ON_MISSING_ERROR {
+		return fmt.Errorf("%s: %v", e, err)
+	}
+	if len(values) == 0 {
+		switch e.OnMissing {
+		case ON_MISSING_DEFAULT:
+			vars[e.Variable] = e.Default
+		case ON_MISSING_ERROR:
+			return fmt.Errorf("%s: nothing found for %s", e, e.Variable)
+		}
+		return nil
+	}
+	var value interface{}
+	switch e.Index {
+	case testdef.ALL:
+		value = values
+	case testdef.LAST:
+		value = values[len(values)-1]
+	case testdef.RANDOM:
+		value = values[rand.Intn(len(values))]
+	default:
+		value = values[0]
+	}
+	s, err := sessionValue(value)
+	if err != nil {
+		return fmt.Errorf("%s: %v", e, err)
+	}
+	vars[e.Variable] = s
+	return nil
+}
+
+// sessionValue returns how v is stored in a session: strings as they are, anything
+// else as JSON.
+func sessionValue(v interface{}) (string, error) {
+	if s, ok := v.(string); ok {
+		return s, nil
+	}
+	b, err := json.Marshal(v)
+	return string(b), err
+}
+
+// find returns all the values e matches.
+func (e HttpExtractor) find(resp *http.Response, body []byte) ([]interface{}, error) {
+	var values []string
+	switch e.Source {
+	case SOURCE_HEADER:
+		values = resp.Header[http.CanonicalHeaderKey(e.Name)]
+	case SOURCE_COOKIE:
+		for _, c := range resp.Cookies() {
+			if c.Name == e.Name {
+				values = append(values, c.Value)
+			}
+		}
+	case SOURCE_STATUS:
+		values = []string{strconv.Itoa(resp.StatusCode)}
+	default:
+		if e.jsonpath != nil {
+			return findJsonpath(e.jsonpath, e.Jsonpath, body)
+		}
+		var err error
+		switch {
+		case e.xmlpath != nil:
+			values, err = findXmlpath(e.xmlpath, body)
+		case e.css != nil:
+			values, err = e.css.find(body, e.Attribute)
+		default:
+			values = []string{string(body)}
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	switch {
+	case e.regex != nil:
+		values = findRegex(e.regex, values)
+	case e.Left != "" || e.Right != "":
+		values = findBoundary(e.Left, e.Right, values)
+	}
+	found := make([]interface{}, len(values))
+	for i, v := range values {
+		found[i] = v
+	}
+	return found, nil
+}
+
+// findRegex returns the first capture group of every match of re in texts, or the
+// whole match if re has no groups.
+func findRegex(re *regexp.Regexp, texts []string) []string {
+	var values []string
+	for _, text := range texts {
+		for _, m := range re.FindAllStringSubmatch(text, -1) {
+			if len(m) > 1 {
+				values = append(values, m[1])
+			} else {
+				values = append(values, m[0])
+			}
+		}
+	}
+	return values
+}
+
+// findBoundary returns every part of texts between left and right. An empty left
+// boundary is the start of a text, an empty right one its end.
+func findBoundary(left, right string, texts []string) []string {
+	var values []string
+	for _, text := range texts {
+		for {
+			i := strings.Index(text, left)
+			if i < 0 {
+				break
+			}
+			text = text[i+len(left):]
+			if right == "" {
+				values = append(values, text)
+				break
+			}
+			j := strings.Index(text, right)
+			if j < 0 {
+				break
+			}
+			values = append(values, text[:j])
+			text = text[j+len(right):]
+			if left == "" {
+				break
+			}
+		}
+	}
+	return values
+}
+
+// findJsonpath returns the values path matches in body. A definite path, one without
+// wildcards, filters, slices or unions, matches a single value, which may be an array;
+// the others match a list of values.
+func findJsonpath(path *jsonpath.Compiled, expr string, body []byte) ([]interface{}, error) {
+	var jsonData interface{}
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	if err := dec.Decode(&jsonData); err != nil {
+		return nil, err
+	}
+	res, err := path.Lookup(jsonData)
+	if err != nil {
+		// The lookup fails when the path is not in the document.
+		return nil, nil
+	}
+	if list, ok := res.([]interface{}); ok && !definitePath(expr) {
+		return list, nil
+	}
+	return []interface{}{res}, nil
+}
+
+func definitePath(expr string) bool {
+	return !strings.Contains(expr, "..") && !strings.ContainsAny(expr, "*?:,")
+}
+
+func findXmlpath(path *xmlpath.Path, body []byte) ([]string, error) {
+	root, err := xmlpath.Parse(bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	var values []string
+	for iter := path.Iter(root); iter.Next(); {
+		values = append(values, iter.Node().String())
+	}
+	return values, nil
+}
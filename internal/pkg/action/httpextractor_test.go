@@ -0,0 +1,142 @@
+package action
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/botcliq/loadzy/internal/pkg/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetExtractors_Extract(t *testing.T) {
+	a := map[interface{}]interface{}{"response": []interface{}{
+		map[interface{}]interface{}{"jsonpath": "$.token", "variable": "token"},
+		map[interface{}]interface{}{"jsonpath": "$.users[*].id", "variable": "lastId", "index": "last"},
+		map[interface{}]interface{}{"source": "header", "name": "x-user-id", "variable": "userId"},
+		map[interface{}]interface{}{"source": "cookie", "name": "session", "variable": "session"},
+		map[interface{}]interface{}{"source": "status", "variable": "status"},
+		map[interface{}]interface{}{"jsonpath": "$.missing", "variable": "role", "onMissing": "default", "default": "guest"},
+		map[interface{}]interface{}{"source": "header", "name": "X-Missing", "variable": "skipped", "onMissing": "skip"},
+	}}
+	extractors, ok := getExtractors(a)
+	assert.True(t, ok)
+	assert.Len(t, extractors, 7)
+
+	rec := httptest.NewRecorder()
+	rec.Header().Set("X-User-Id", "7")
+	http.SetCookie(rec, &http.Cookie{Name: "session", Value: "abc"})
+	rec.WriteHeader(201)
+	resp := rec.Result()
+	body := []byte(`{"token": "t0k3n", "users": [{"id": "1"}, {"id": "2"}]}`)
+
+	vars := map[string]string{}
+	for _, e := range extractors {
+		assert.NoError(t, e.Extract(resp, body, vars), e.String())
+	}
+	assert.Equal(t, map[string]string{"token": "t0k3n", "lastId": "2", "userId": "7", "session": "abc", "status": "201", "role": "guest"}, vars)
+
+	missing, _ := getExtractors(map[interface{}]interface{}{"response": map[interface{}]interface{}{"source": "header", "name": "X-Missing", "variable": "v"}})
+	assert.EqualError(t, missing[0].Extract(resp, body, vars), "header X-Missing: nothing found for v")
+}
+
+func TestGetExtractors_RejectsInvalid(t *testing.T) {
+	for _, r := range []map[interface{}]interface{}{
+		{"jsonpath": "$.token"},
+		{"jsonpath": "$.token", "variable": "v", "index": "middle"},
+		{"jsonpath": "$.token", "variable": "v", "onMissing": "ignore"},
+		{"jsonpath": "$.token", "xmlpath": "//token", "variable": "v"},
+		{"source": "header", "variable": "v"},
+		{"source": "trailer", "variable": "v"},
+		{"jsonpath": "$.token", "variable": "v", "unknown": true},
+	} {
+		_, ok := getExtractors(map[interface{}]interface{}{"response": r})
+		assert.False(t, ok, "%v", r)
+	}
+}
+
+func TestGetExtractors_ExtractText(t *testing.T) {
+	a := map[interface{}]interface{}{"response": []interface{}{
+		map[interface{}]interface{}{"css": "form#login input[name=csrf]", "attribute": "value", "variable": "csrf"},
+		map[interface{}]interface{}{"css": "ul.items > li", "variable": "lastItem", "index": "last"},
+		map[interface{}]interface{}{"regex": `order-(\d+)`, "variable": "order"},
+		map[interface{}]interface{}{"regex": `[A-Z]{3}-\d`, "variable": "code"},
+		map[interface{}]interface{}{"left": "<title>", "right": "</title>", "variable": "title"},
+		map[interface{}]interface{}{"source": "header", "name": "Set-Cookie", "regex": `^JSESSIONID=([^;]+)`, "variable": "jsession"},
+		map[interface{}]interface{}{"source": "header", "name": "Location", "left": "id=", "variable": "id"},
+	}}
+	extractors, ok := getExtractors(a)
+	assert.True(t, ok)
+
+	rec := httptest.NewRecorder()
+	rec.Header().Set("Location", "/orders?id=42")
+	http.SetCookie(rec, &http.Cookie{Name: "JSESSIONID", Value: "s3ss", Path: "/"})
+	resp := rec.Result()
+	body := []byte(`<html><head><title>Orders</title></head><body>
+		<form id="search"><input name="csrf" value="wrong"></form>
+		<form id="login" method="post">
+			<div><input type="hidden" name="csrf" value="t0k3n"><input name="user"></div>
+		</form>
+		<ul class="items"><li>order-17</li><li> <b>ABC-1</b> order-18 </li></ul>
+	</body></html>`)
+
+	vars := map[string]string{}
+	for _, e := range extractors {
+		assert.NoError(t, e.Extract(resp, body, vars), e.String())
+	}
+	assert.Equal(t, map[string]string{"csrf": "t0k3n", "lastItem": "ABC-1 order-18", "order": "17", "code": "ABC-1",
+		"title": "Orders", "jsession": "s3ss", "id": "42"}, vars)
+}
+
+func TestGetExtractors_RejectsInvalidText(t *testing.T) {
+	for _, r := range []map[interface{}]interface{}{
+		{"regex": "order-(\\d+", "variable": "v"},
+		{"css": "form[name=", "variable": "v"},
+		{"css": "a ~ b", "variable": "v"},
+		{"css": "input", "regex": "x", "variable": "v"},
+		{"attribute": "value", "variable": "v"},
+		{"source": "header", "name": "X-Html", "css": "input", "variable": "v"},
+	} {
+		_, ok := getExtractors(map[interface{}]interface{}{"response": r})
+		assert.False(t, ok, "%v", r)
+	}
+}
+
+func TestGetExtractors_ExtractTyped(t *testing.T) {
+	a := map[interface{}]interface{}{"response": []interface{}{
+		map[interface{}]interface{}{"jsonpath": "$.total", "variable": "total"},
+		map[interface{}]interface{}{"jsonpath": "$.id", "variable": "id"},
+		map[interface{}]interface{}{"jsonpath": "$.next", "variable": "next"},
+		map[interface{}]interface{}{"jsonpath": "$.paged", "variable": "paged"},
+		map[interface{}]interface{}{"jsonpath": "$.items", "variable": "items"},
+		map[interface{}]interface{}{"jsonpath": "$.items[0]", "variable": "item"},
+		map[interface{}]interface{}{"jsonpath": "$.items[*].price", "variable": "prices", "index": "all"},
+		map[interface{}]interface{}{"jsonpath": "$.items[*].price", "variable": "lastPrice", "index": "last"},
+		map[interface{}]interface{}{"css": "li", "variable": "names", "index": "all"},
+	}}
+	extractors, ok := getExtractors(a)
+	assert.True(t, ok)
+
+	body := []byte(`{"total": 2, "id": 12345678901234567890, "next": null, "paged": true,
+		"items": [{"name": "a", "price": 1.5}, {"name": "b", "price": 20}]}`)
+	vars := map[string]string{}
+	for _, e := range extractors[:8] {
+		assert.NoError(t, e.Extract(httptest.NewRecorder().Result(), body, vars), e.String())
+	}
+	assert.NoError(t, extractors[8].Extract(httptest.NewRecorder().Result(), []byte("<ul><li>a</li><li>b</li></ul>"), vars))
+	assert.Equal(t, map[string]string{
+		"total":     "2",
+		"id":        "12345678901234567890",
+		"next":      "null",
+		"paged":     "true",
+		"items":     `[{"name":"a","price":1.5},{"name":"b","price":20}]`,
+		"item":      `{"name":"a","price":1.5}`,
+		"prices":    "[1.5,20]",
+		"lastPrice": "20",
+		"names":     `["a","b"]`,
+	}, vars)
+
+	got, err := util.Render(`{"items": ${items | raw}, "first": "${items[0].name}", "count": ${items | length}}`, vars, util.EscapeJSON)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"items": [{"name":"a","price":1.5},{"name":"b","price":20}], "first": "a", "count": 2}`, got)
+}
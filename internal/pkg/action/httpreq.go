@@ -24,37 +24,40 @@ SOFTWARE.
 package action
 
 import (
-	"bytes"
-	"crypto/tls"
-	"encoding/json"
+	"context"
 	"fmt"
 	"io/ioutil"
 	"log"
-	"math/rand"
 	"net/http"
 	"net/http/httputil"
-	"reflect"
 	"strings"
 	"time"
 
 	"github.com/botcliq/loadzy/internal/pkg/result"
 	"github.com/botcliq/loadzy/internal/pkg/runtime"
 	"github.com/botcliq/loadzy/internal/pkg/stats"
-	"github.com/botcliq/loadzy/internal/pkg/testdef"
 	"github.com/botcliq/loadzy/internal/pkg/util"
-	"github.com/oliveagle/jsonpath"
-	"gopkg.in/xmlpath.v2"
 )
 
-// Accepts a Httpaction and a one-way channel to write the results to.
-func DoHttpRequest(httpAction HttpAction, resultsChannel chan result.HttpReqResult, sessionMap map[string]string) {
-	req := buildHttpRequest(httpAction, sessionMap)
+// Accepts a context that aborts the request when cancelled, a Httpaction and the Env to execute it in.
+// Returns an error if the request could not be sent or its response not be read or processed; these
+// are recorded in the stats as well.
+func DoHttpRequest(ctx context.Context, httpAction HttpAction, env *Env) error {
+	scheduled := env.scheduled()
+	sessionMap := env.Session
+	r := stats.Result{Attack: "HTTP load", Title: httpAction.Title, Method: httpAction.Method, URL: httpAction.Url, Scenario: env.Scenario}
+	req, err := buildHttpRequest(httpAction, sessionMap)
+	if err != nil {
+		r.Timestamp = time.Now()
+		r.Error = err.Error()
+		stats.AddError(1, &r)
+		return err
+	}
 
 	start := time.Now()
-	r := stats.Result{Attack: "HTTP load"}
-	var DefaultTransport http.RoundTripper = &http.Transport{
-		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-	}
+	ctx, cancel := context.WithTimeout(ctx, httpAction.Timeout)
+	defer cancel()
+	req = req.WithContext(ctx)
 	stats.AddRequest(1, fmt.Sprintf("[%s:%s]->", httpAction.Url, httpAction.Method))
 	dumpedBody, err := httputil.DumpRequest(req, true)
 
@@ -64,47 +67,59 @@ func DoHttpRequest(httpAction HttpAction, resultsChannel chan result.HttpReqResu
 		r.BytesOut = uint64(len(dumpedBody))
 	}
 
-	resp, err := DefaultTransport.RoundTrip(req)
+	resp, timer, err := httpAction.roundTrip(req, env)
 
 	if err != nil {
 		log.Printf("HTTP request failed: %s", err)
+		r.Timestamp = time.Now()
+		r.Code = fmt.Sprintf("[%s:%d]->", httpAction.Url, 0)
+		r.Error = err.Error()
+		r.Latency = time.Since(start)
+		r.ResponseTime = time.Since(scheduled)
+		stats.Add(1, &r)
+		env.setStatus(0)
+		verifyChecks(httpAction.Title, httpAction.Checks, sessionMap, nil, nil, time.Since(start))
+		return err
 	} else {
 		elapsed := time.Since(start)
 		responseBody, err := ioutil.ReadAll(resp.Body)
+		// Always drain and close the body so the connection can go back to the pool.
+		resp.Body.Close()
 		r.Timestamp = time.Now()
 		r.Code = fmt.Sprintf("[%s:%d]->", httpAction.Url, resp.StatusCode)
 		r.Status = resp.StatusCode
 		r.BytesIn = uint64(len(responseBody))
 		r.Latency = elapsed
+		r.ResponseTime = time.Since(scheduled)
+		r.Timings = timer.done()
 
 		stats.Add(1, &r)
+		env.setStatus(resp.StatusCode)
+		checkErr := verifyChecks(httpAction.Title, httpAction.Checks, sessionMap, resp, responseBody, elapsed)
 
 		if err != nil {
-			//log.Fatal(err)
 			log.Printf("Reading HTTP response failed: %s\n", err)
-			httpReqResult := buildHttpResult(0, resp.StatusCode, elapsed.Nanoseconds(), httpAction.Title)
-
-			resultsChannel <- httpReqResult
+			r.Error = err.Error()
+			stats.AddError(1, &r)
+			env.report(buildHttpResult(0, resp.StatusCode, elapsed.Nanoseconds(), httpAction.Title))
+			return err
 		} else {
-			defer resp.Body.Close()
+			env.report(buildHttpResult(len(responseBody), resp.StatusCode, elapsed.Nanoseconds(), httpAction.Title))
 
-			if httpAction.StoreCookie != "" {
-				for _, cookie := range resp.Cookies() {
-
-					if cookie.Name == httpAction.StoreCookie {
-						sessionMap["____"+cookie.Name] = cookie.Value
-					}
-				}
+			// if action specifies extractors, store what they find in the session
+			if err := processResult(httpAction, sessionMap, resp, responseBody); err != nil {
+				r.Error = err.Error()
+				stats.AddError(1, &r)
+				return err
+			}
+			if checkErr != nil {
+				r.Error = checkErr.Error()
+				stats.AddError(1, &r)
+				return checkErr
 			}
-
-			// if action specifies response action, parse using regexp/jsonpath
-			processResult(httpAction, sessionMap, responseBody)
-
-			httpReqResult := buildHttpResult(len(responseBody), resp.StatusCode, elapsed.Nanoseconds(), httpAction.Title)
-
-			resultsChannel <- httpReqResult
 		}
 	}
+	return nil
 }
 
 func buildHttpResult(contentLength int, status int, elapsed int64, title string) result.HttpReqResult {
@@ -119,161 +134,75 @@ func buildHttpResult(contentLength int, status int, elapsed int64, title string)
 	return httpReqResult
 }
 
-func buildHttpRequest(httpAction HttpAction, sessionMap map[string]string) *http.Request {
+func buildHttpRequest(httpAction HttpAction, sessionMap map[string]string) (*http.Request, error) {
 	var req *http.Request
-	var err error
-	if httpAction.Body != "" {
-		reader := strings.NewReader(util.SubstParams(sessionMap, httpAction.Body))
-		req, err = http.NewRequest(httpAction.Method, util.SubstParams(sessionMap, httpAction.Url), reader)
-	} else if httpAction.Template != "" {
-		reader := strings.NewReader(util.SubstParams(sessionMap, httpAction.Template))
-		req, err = http.NewRequest(httpAction.Method, util.SubstParams(sessionMap, httpAction.Url), reader)
-	} else {
-		req, err = http.NewRequest(httpAction.Method, util.SubstParams(sessionMap, httpAction.Url), nil)
-	}
+	target, err := util.Render(httpAction.Url, sessionMap, util.EscapeURL)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
-
-	// Add headers
-	req.Header.Add("Accept", httpAction.Accept)
-	if httpAction.ContentType != "" {
-		req.Header.Add("Content-Type", httpAction.ContentType)
-	}
-
-	for key, value := range httpAction.Headers {
-		req.Header.Add(key, value)
+	body := httpAction.Body
+	if body == "" {
+		body = httpAction.Template
 	}
-
-	if hostHeader, found := httpAction.Headers["host"]; found {
-		req.Host = hostHeader
+	if body != "" {
+		if body, err = util.Render(body, sessionMap, bodyEscape(httpAction.ContentType, body)); err != nil {
+			return nil, err
+		}
+		req, err = http.NewRequest(httpAction.Method, target, strings.NewReader(body))
+	} else {
+		req, err = http.NewRequest(httpAction.Method, target, nil)
 	}
-
-	if hostHeader, found := httpAction.Headers["Host"]; found {
-		req.Host = hostHeader
+	if err != nil {
+		return nil, err
 	}
 
-	// Add cookies stored by subsequent requests in the sessionMap having the kludgy ____ prefix
-	for key, value := range sessionMap {
-		if strings.HasPrefix(key, "____") {
-
-			cookie := http.Cookie{
-				Name:  key[4:],
-				Value: value,
-			}
-
-			req.AddCookie(&cookie)
-		}
+	// Add headers
+	accept, err := util.Render(httpAction.Accept, sessionMap, util.EscapeNone)
+	if err != nil {
+		return nil, err
 	}
-
-	return req
-}
-
-/**
- * If the httpAction specifies a Jsonpath in the Response, try to extract value(s)
- * from the responseBody.
- *
- * TODO extract both Jsonpath handling and Xmlpath handling into separate functions, and write tests for them.
- */
-func processResult(httpAction HttpAction, sessionMap map[string]string, responseBody []byte) {
-	if httpAction.ResponseHandler.Jsonpath != "" {
-		jsonPattern, err := jsonpath.Compile(httpAction.ResponseHandler.Jsonpath)
-		if err != nil {
-			log.Fatal(err)
-		}
-
-		var jsonData interface{}
-		json.Unmarshal(responseBody, &jsonData)
-
-		res, err := jsonPattern.Lookup(jsonData)
+	req.Header.Add("Accept", accept)
+	if httpAction.ContentType != "" {
+		contentType, err := util.Render(httpAction.ContentType, sessionMap, util.EscapeNone)
 		if err != nil {
-			log.Fatal(err)
+			return nil, err
 		}
-
-		var resultArray []string
-		v := reflect.ValueOf(res)
-		switch v.Kind() {
-		case reflect.String:
-			resultArray = []string{res.(string)}
-		case reflect.Slice:
-			a := res.([]interface{})
-			resultArray = make([]string, len(a))
-			for idx, val := range a {
-				resultArray[idx] = fmt.Sprintf("%s", val)
-			}
-		default:
-			log.Printf("Unknown type [%T]", reflect.TypeOf(res))
-		}
-		passResultIntoSessionMap(resultArray, httpAction, sessionMap)
+		req.Header.Add("Content-Type", contentType)
 	}
 
-	if httpAction.ResponseHandler.Xmlpath != "" {
-		path := xmlpath.MustCompile(httpAction.ResponseHandler.Xmlpath)
-		r := bytes.NewReader(responseBody)
-		root, err := xmlpath.Parse(r)
-
+	for key, value := range httpAction.Headers {
+		value, err := util.Render(value, sessionMap, util.EscapeNone)
 		if err != nil {
-			log.Fatal(err)
+			return nil, fmt.Errorf("header %s: %v", key, err)
 		}
-
-		iterator := path.Iter(root)
-		hasNext := iterator.Next()
-		if hasNext {
-			resultsArray := make([]string, 0, 10)
-			for {
-				if hasNext {
-					node := iterator.Node()
-					resultsArray = append(resultsArray, node.String())
-					hasNext = iterator.Next()
-				} else {
-					break
-				}
-			}
-			passResultIntoSessionMap(resultsArray, httpAction, sessionMap)
+		req.Header.Add(key, value)
+		if strings.EqualFold(key, "host") {
+			req.Host = value
 		}
 	}
 
-	// log.Println(string(responseBody))
+	return req, nil
 }
 
-/**
- * Trims leading and trailing byte r from string s
- */
-func trimChar(s string, r byte) string {
-	sz := len(s)
-
-	if sz > 0 && s[sz-1] == r {
-		s = s[:sz-1]
+// bodyEscape returns how values are escaped in a body: for use inside JSON strings if
+// the content type is JSON, or it isn't set and the body looks like JSON, else not at all.
+func bodyEscape(contentType, body string) util.Escape {
+	if strings.Contains(contentType, "json") {
+		return util.EscapeJSON
 	}
-	sz = len(s)
-	if sz > 0 && s[0] == r {
-		s = s[1:sz]
+	if trimmed := strings.TrimSpace(body); contentType == "" && (strings.HasPrefix(trimmed, "{") || strings.HasPrefix(trimmed, "[")) {
+		return util.EscapeJSON
 	}
-	return s
+	return util.EscapeNone
 }
 
-func passResultIntoSessionMap(resultsArray []string, httpAction HttpAction, sessionMap map[string]string) {
-	resultCount := len(resultsArray)
-
-	if resultCount > 0 {
-		switch httpAction.ResponseHandler.Index {
-		case testdef.FIRST:
-			sessionMap[httpAction.ResponseHandler.Variable] = resultsArray[0]
-			break
-		case testdef.LAST:
-			sessionMap[httpAction.ResponseHandler.Variable] = resultsArray[resultCount-1]
-			break
-		case testdef.RANDOM:
-			if resultCount > 1 {
-				rand.Seed(time.Now().UnixNano())
-				sessionMap[httpAction.ResponseHandler.Variable] = resultsArray[rand.Intn(resultCount-1)]
-			} else {
-				sessionMap[httpAction.ResponseHandler.Variable] = resultsArray[0]
-			}
-			break
+// processResult stores the values the extractors of httpAction find in the response
+// into the session.
+func processResult(httpAction HttpAction, sessionMap map[string]string, resp *http.Response, responseBody []byte) error {
+	for _, e := range httpAction.Extractors {
+		if err := e.Extract(resp, responseBody, sessionMap); err != nil {
+			return err
 		}
-
-	} else {
-		// TODO how to handle requested, but missing result?
 	}
+	return nil
 }
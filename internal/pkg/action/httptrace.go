@@ -0,0 +1,84 @@
+package action
+
+import (
+	"crypto/tls"
+	"net/http/httptrace"
+	"sync"
+	"time"
+
+	"github.com/botcliq/loadzy/internal/pkg/stats"
+)
+
+// phaseTimer measures the phases of one http request through httptrace. The trace
+// hooks may be called from the transport's dialing goroutines, hence the lock.
+type phaseTimer struct {
+	mu           sync.Mutex
+	dnsStart     time.Time
+	connectStart time.Time
+	tlsStart     time.Time
+	wroteRequest time.Time
+	firstByte    time.Time
+	timings      stats.Timings
+}
+
+func newPhaseTimer() *phaseTimer {
+	return &phaseTimer{}
+}
+
+func (p *phaseTimer) trace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) {
+			p.mu.Lock()
+			p.dnsStart = time.Now()
+			p.mu.Unlock()
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			p.mu.Lock()
+			p.timings.DNS = time.Since(p.dnsStart)
+			p.mu.Unlock()
+		},
+		ConnectStart: func(network, addr string) {
+			p.mu.Lock()
+			p.connectStart = time.Now()
+			p.mu.Unlock()
+		},
+		ConnectDone: func(network, addr string, err error) {
+			p.mu.Lock()
+			p.timings.Connect = time.Since(p.connectStart)
+			p.mu.Unlock()
+		},
+		TLSHandshakeStart: func() {
+			p.mu.Lock()
+			p.tlsStart = time.Now()
+			p.mu.Unlock()
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			p.mu.Lock()
+			p.timings.TLSHandshake = time.Since(p.tlsStart)
+			p.mu.Unlock()
+		},
+		WroteRequest: func(httptrace.WroteRequestInfo) {
+			p.mu.Lock()
+			p.wroteRequest = time.Now()
+			p.mu.Unlock()
+		},
+		GotFirstResponseByte: func() {
+			p.mu.Lock()
+			p.firstByte = time.Now()
+			if !p.wroteRequest.IsZero() {
+				p.timings.FirstByte = p.firstByte.Sub(p.wroteRequest)
+			}
+			p.mu.Unlock()
+		},
+	}
+}
+
+// done marks the end of reading the response body and returns the timings.
+func (p *phaseTimer) done() stats.Timings {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.firstByte.IsZero() {
+		p.timings.Transfer = time.Since(p.firstByte)
+	}
+	return p.timings
+}
@@ -0,0 +1,30 @@
+package action
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/http/httptrace"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPhaseTimer_FirstByteIsServerWait(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(50 * time.Millisecond)
+	}))
+	defer srv.Close()
+
+	timer := newPhaseTimer()
+	req, _ := http.NewRequest("GET", srv.URL, nil)
+	start := time.Now()
+	resp, err := http.DefaultTransport.RoundTrip(req.WithContext(httptrace.WithClientTrace(req.Context(), timer.trace())))
+	assert.NoError(t, err)
+	resp.Body.Close()
+	timings := timer.done()
+	total := time.Since(start)
+
+	assert.True(t, timings.FirstByte >= 50*time.Millisecond, timings.FirstByte)
+	assert.True(t, timings.Connect+timings.FirstByte+timings.Transfer <= total, timings)
+}
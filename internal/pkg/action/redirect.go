@@ -0,0 +1,146 @@
+package action
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptrace"
+	"time"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/botcliq/loadzy/internal/pkg/stats"
+	"github.com/botcliq/loadzy/internal/pkg/testdef"
+)
+
+// getRedirects returns how far an http action follows redirects: as set on the
+// action itself, or else as set globally in the test definition.
+func getRedirects(action map[interface{}]interface{}, global testdef.Redirects) (testdef.Redirects, error) {
+	if action["followRedirects"] == nil {
+		return global, nil
+	}
+	raw, err := yaml.Marshal(action["followRedirects"])
+	if err != nil {
+		return global, err
+	}
+	var own testdef.Redirects
+	err = yaml.Unmarshal(raw, &own)
+	return own, err
+}
+
+// roundTrip sends req and follows the redirects of the responses as far as the action
+// is set to. Cookies set along the way are sent with the redirected requests, even when
+// the user keeps no cookies. It returns the final response and the phase timer of its
+// request; with recordHops every redirect response is recorded as a Result of its own.
+func (h HttpAction) roundTrip(req *http.Request, env *Env) (*http.Response, *phaseTimer, error) {
+	rt := h.roundTripper(env.State)
+	jar := h.jar(env.State)
+	if jar == nil && h.FollowRedirects.Max > 0 {
+		jar = newJar()
+	}
+	for hop := 0; ; hop++ {
+		start := time.Now()
+		timer := newPhaseTimer()
+		if jar != nil {
+			for _, cookie := range jar.Cookies(req.URL) {
+				req.AddCookie(cookie)
+			}
+		}
+		resp, err := rt.RoundTrip(req.WithContext(httptrace.WithClientTrace(req.Context(), timer.trace())))
+		if err != nil {
+			return nil, timer, err
+		}
+		if jar != nil {
+			jar.SetCookies(req.URL, resp.Cookies())
+		}
+		next, err := h.redirect(req, resp, hop)
+		if next == nil && err == nil {
+			return resp, timer, nil
+		}
+		io.Copy(ioutil.Discard, resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, timer, err
+		}
+		if h.FollowRedirects.RecordHops {
+			stats.Add(1, &stats.Result{
+				Attack:    "HTTP load",
+				Title:     fmt.Sprintf("%s (redirect %d)", h.Title, hop+1),
+				Hop:       hop + 1,
+				Method:    req.Method,
+				URL:       req.URL.String(),
+				Code:      fmt.Sprintf("[%s:%d]->", req.URL, resp.StatusCode),
+				Status:    resp.StatusCode,
+				Timestamp: time.Now(),
+				Latency:   time.Since(start),
+				Timings:   timer.done(),
+				Scenario:  env.Scenario,
+			})
+		}
+		req = next
+	}
+}
+
+// redirect returns the request to follow the redirect resp to req with, or nil if resp
+// is not a redirect or the action does not follow it. Like browsers do, 301, 302 and 303
+// redirects are followed with a GET, 307 and 308 ones with the same method and body.
+func (h HttpAction) redirect(req *http.Request, resp *http.Response, hops int) (*http.Request, error) {
+	method, body := req.Method, req.GetBody
+	switch resp.StatusCode {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther:
+		if method != http.MethodHead {
+			method = http.MethodGet
+		}
+		body = nil
+	case http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		if req.Body != nil && req.Body != http.NoBody && body == nil {
+			return nil, nil
+		}
+	default:
+		return nil, nil
+	}
+	location := resp.Header.Get("Location")
+	if h.FollowRedirects.Max == 0 || location == "" {
+		return nil, nil
+	}
+	if hops >= h.FollowRedirects.Max {
+		return nil, fmt.Errorf("stopped after %d redirects", h.FollowRedirects.Max)
+	}
+	u, err := req.URL.Parse(location)
+	if err != nil {
+		return nil, fmt.Errorf("invalid redirect location %q: %v", location, err)
+	}
+
+	next, err := http.NewRequest(method, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	next = next.WithContext(req.Context())
+	if body != nil {
+		if next.Body, err = body(); err != nil {
+			return nil, err
+		}
+		next.GetBody, next.ContentLength = body, req.ContentLength
+	}
+	for key, values := range req.Header {
+		switch http.CanonicalHeaderKey(key) {
+		case "Cookie":
+			// The cookies for the new URL come from the jar.
+			continue
+		case "Authorization":
+			if u.Host != req.URL.Host {
+				continue
+			}
+		case "Content-Type", "Content-Length":
+			if body == nil {
+				continue
+			}
+		}
+		next.Header[key] = values
+	}
+	if u.Host == req.URL.Host {
+		next.Host = req.Host
+	}
+	return next, nil
+}
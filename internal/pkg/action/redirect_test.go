@@ -0,0 +1,76 @@
+package action
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/botcliq/loadzy/internal/pkg/stats"
+	"github.com/botcliq/loadzy/internal/pkg/testdef"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDoHttpRequest_FollowsRedirects(t *testing.T) {
+	var got []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		got = append(got, r.Method+" "+r.URL.Path+" "+string(body)+" "+r.Header.Get("Cookie"))
+		switch r.URL.Path {
+		case "/old":
+			http.SetCookie(w, &http.Cookie{Name: "visited", Value: "yes"})
+			http.Redirect(w, r, "/keep", http.StatusFound)
+		case "/keep":
+			http.Redirect(w, r, "/new", http.StatusTemporaryRedirect)
+		case "/loop":
+			http.Redirect(w, r, "/loop", http.StatusFound)
+		}
+	}))
+	defer srv.Close()
+	td := &testdef.TestDef{}
+	td.Http.Cookies.Disabled = true
+	newAction := func(method, path string, follow interface{}) HttpAction {
+		a := map[interface{}]interface{}{"title": "redirected", "method": method, "url": srv.URL + path, "body": "data"}
+		if follow != nil {
+			a["followRedirects"] = follow
+		}
+		return NewHttpAction(a, td)
+	}
+
+	assert.NoError(t, newAction("POST", "/keep", true).Execute(context.Background(), &Env{}))
+	assert.Equal(t, []string{"POST /keep data ", "POST /new data "}, got)
+
+	got = nil
+	assert.NoError(t, newAction("POST", "/old", 5).Execute(context.Background(), &Env{}))
+	assert.Equal(t, []string{"POST /old data ", "GET /keep  visited=yes", "GET /new  visited=yes"}, got)
+
+	got = nil
+	assert.NoError(t, newAction("POST", "/old", nil).Execute(context.Background(), &Env{}))
+	assert.Equal(t, []string{"POST /old data "}, got)
+
+	err := newAction("GET", "/loop", 3).Execute(context.Background(), &Env{})
+	assert.EqualError(t, err, "stopped after 3 redirects")
+}
+
+func TestDoHttpRequest_RecordsRedirectHops(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/old" {
+			http.Redirect(w, r, "/new", http.StatusMovedPermanently)
+		}
+	}))
+	defer srv.Close()
+	stats.ClearOrAddMetrics(1)
+	a := map[interface{}]interface{}{"title": "Moved", "method": "GET", "url": srv.URL + "/old",
+		"followRedirects": map[interface{}]interface{}{"max": 2, "recordHops": true}}
+	assert.NoError(t, NewHttpAction(a, &testdef.TestDef{}).Execute(context.Background(), &Env{}))
+
+	m := stats.GetMetric(1)
+	assert.Equal(t, uint64(1), m.Groups["Moved"].Requests)
+	assert.Equal(t, map[string]int{"200": 1}, m.Groups["Moved"].StatusCodes)
+	hop := m.Groups["Moved (redirect 1)"]
+	if assert.NotNil(t, hop) {
+		assert.Equal(t, map[string]int{"301": 1}, hop.StatusCodes)
+	}
+	assert.Equal(t, 1, m.StatusCodes["["+srv.URL+"/old:200]->"])
+}
@@ -24,18 +24,55 @@ SOFTWARE.
 package action
 
 import (
+	"context"
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
-	"github.com/botcliq/loadzy/internal/pkg/result"
+	"github.com/botcliq/loadzy/internal/pkg/util"
 )
 
 type SleepAction struct {
 	Duration time.Duration `yaml:"duration"`
+
+	// template is the duration if it uses variables, resolved on every Execute.
+	template string
+}
+
+// Execute sleeps for the duration, but wakes up early if ctx is cancelled.
+func (s SleepAction) Execute(ctx context.Context, env *Env) error {
+	d := s.Duration
+	if s.template != "" {
+		var err error
+		if d, err = s.resolve(env.Session); err != nil {
+			return err
+		}
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+	return nil
 }
 
-func (s SleepAction) Execute(resultsChannel chan result.HttpReqResult, sessionMap map[string]string) {
-	time.Sleep(s.Duration)
+// resolve returns the duration of the template with its variables replaced by their
+// values in vars: a number of seconds or a Go duration like 1500ms.
+func (s SleepAction) resolve(vars map[string]string) (time.Duration, error) {
+	val, err := util.Render(s.template, vars, util.EscapeNone)
+	if err != nil {
+		return 0, fmt.Errorf("sleep duration: %v", err)
+	}
+	if secs, err := strconv.Atoi(val); err == nil {
+		return time.Second * time.Duration(secs), nil
+	}
+	d, err := time.ParseDuration(val)
+	if err != nil {
+		return 0, fmt.Errorf("sleep duration: %v", err)
+	}
+	return d, nil
 }
 
 func NewSleepAction(a map[interface{}]interface{}) SleepAction {
@@ -43,6 +80,13 @@ func NewSleepAction(a map[interface{}]interface{}) SleepAction {
 	case int:
 		return SleepAction{Duration: time.Second * time.Duration(val)}
 	case string:
+		if strings.Contains(val, "${") {
+			if _, err := util.ParseTemplate(val); err != nil {
+				fmt.Printf("Error trying to parse duration '%v'. Error: %v\n", val, err.Error())
+				panic(err.Error())
+			}
+			return SleepAction{template: val}
+		}
 		dur, err := time.ParseDuration(val)
 		if err != nil {
 			fmt.Printf("Error trying to parse duration '%v' from string representation into Go duration format. Error: %v\n", val, err.Error())
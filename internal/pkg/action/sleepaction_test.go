@@ -1,6 +1,7 @@
 package action
 
 import (
+	"context"
 	"github.com/stretchr/testify/assert"
 	"testing"
 	"time"
@@ -9,13 +10,20 @@ import (
 func TestSleepAction_ExecuteWithDurationFormat(t *testing.T) {
 	action := NewSleepAction(map[interface{}]interface{}{"duration":"100ms"})
 	start := time.Now()
-	action.Execute(nil, nil)
+	action.Execute(context.Background(), &Env{})
 	assert.Greater(t, time.Since(start).Milliseconds(), int64(99))
 }
 
 func TestSleepAction_ExecuteWithIntegerFormat(t *testing.T) {
 	action := NewSleepAction(map[interface{}]interface{}{"duration":1})
 	start := time.Now()
-	action.Execute(nil, nil)
+	action.Execute(context.Background(), &Env{})
 	assert.Greater(t, time.Since(start).Milliseconds(), int64(999))
-}
\ No newline at end of file
+}
+func TestSleepAction_ExecuteWithVariable(t *testing.T) {
+	action := NewSleepAction(map[interface{}]interface{}{"duration": "${pause}"})
+	start := time.Now()
+	assert.NoError(t, action.Execute(context.Background(), &Env{Session: map[string]string{"pause": "20ms"}}))
+	assert.True(t, time.Since(start) >= 20*time.Millisecond)
+	assert.EqualError(t, action.Execute(context.Background(), &Env{}), "sleep duration: unresolved variable pause")
+}
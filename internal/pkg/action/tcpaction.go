@@ -23,7 +23,10 @@ SOFTWARE.
 */
 package action
 
-import "github.com/botcliq/loadzy/internal/pkg/result"
+import (
+	"context"
+	"log"
+)
 
 type TcpAction struct {
 	Address string `yaml:"address"`
@@ -31,16 +34,20 @@ type TcpAction struct {
 	Title   string `yaml:"title"`
 }
 
-func (t TcpAction) Execute(resultsChannel chan result.HttpReqResult, sessionMap map[string]string) {
-	DoTcpRequest(t, resultsChannel, sessionMap)
+func (t TcpAction) Execute(ctx context.Context, env *Env) error {
+	return DoTcpRequest(t, env)
 }
 
 func NewTcpAction(a map[interface{}]interface{}) TcpAction {
 
 	// TODO validation
-	return TcpAction{
+	action := TcpAction{
 		a["address"].(string),
 		a["payload"].(string),
 		a["title"].(string),
 	}
+	if !validTemplates("TcpAction", map[string]string{"address": action.Address, "payload": action.Payload}) {
+		log.Fatalf("Your YAML defintion contains an invalid TcpAction, see errors listed above.")
+	}
+	return action
 }
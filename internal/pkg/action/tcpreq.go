@@ -25,44 +25,55 @@ package action
 
 import (
 	"fmt"
+	"io"
 	"net"
 	"time"
 
 	"github.com/botcliq/loadzy/internal/pkg/result"
 	"github.com/botcliq/loadzy/internal/pkg/runtime"
+	"github.com/botcliq/loadzy/internal/pkg/stats"
 	"github.com/botcliq/loadzy/internal/pkg/util"
 )
 
-var conn net.Conn
+// Accepts a TcpAction and the Env to execute it in. Returns an error if the payload could not be sent.
+func DoTcpRequest(tcpAction TcpAction, env *Env) error {
 
-// Accepts a TcpAction and a one-way channel to write the results to.
-func DoTcpRequest(tcpAction TcpAction, resultsChannel chan result.HttpReqResult, sessionMap map[string]string) {
-
-	address := util.SubstParams(sessionMap, tcpAction.Address)
-	payload := util.SubstParams(sessionMap, tcpAction.Payload)
+	address, err := util.Render(tcpAction.Address, env.Session, util.EscapeNone)
+	var payload string
+	if err == nil {
+		payload, err = util.Render(tcpAction.Payload, env.Session, util.EscapeNone)
+	}
+	if err != nil {
+		stats.AddError(1, &stats.Result{Title: tcpAction.Title, Method: "TCP", URL: tcpAction.Address, Error: err.Error(), Scenario: env.Scenario})
+		return err
+	}
 
-	if conn == nil {
-		var err error
-		conn, err = net.Dial("tcp", address)
-		if err != nil {
-			fmt.Printf("TCP socket closed, error: %s\n", err)
-			conn = nil
-			return
-		}
-		// conn.SetDeadline(time.Now().Add(100 * time.Millisecond))
+	// Every user keeps its own connection per address, outside of a user the
+	// connection only lasts for this request.
+	state := env.State
+	if state == nil {
+		state = NewUserState()
+		defer state.Close()
+	}
+	conn, err := state.conn("tcp", address, func() (net.Conn, error) { return net.Dial("tcp", address) })
+	if err != nil {
+		fmt.Printf("TCP socket closed, error: %s\n", err)
+		stats.AddError(1, &stats.Result{Title: tcpAction.Title, Method: "TCP", URL: address, Error: err.Error(), Scenario: env.Scenario})
+		return err
 	}
 
 	start := time.Now()
 
-	_, err := fmt.Fprintf(conn, payload+"\r\n")
+	_, err = io.WriteString(conn, payload+"\r\n")
 	if err != nil {
 		fmt.Printf("TCP request failed with error: %s\n", err)
-		conn = nil
+		state.dropConn("tcp", address)
+		stats.AddError(1, &stats.Result{Title: tcpAction.Title, Method: "TCP", URL: address, Error: err.Error(), Scenario: env.Scenario})
 	}
 
 	elapsed := time.Since(start)
-	resultsChannel <- buildTcpResult(0, 200, elapsed.Nanoseconds(), tcpAction.Title)
-
+	env.report(buildTcpResult(0, 200, elapsed.Nanoseconds(), tcpAction.Title))
+	return err
 }
 
 func buildTcpResult(contentLength int, status int, elapsed int64, title string) result.HttpReqResult {
@@ -0,0 +1,50 @@
+package action
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDoTcpRequest_RendersAddressAndPayload(t *testing.T) {
+	listen := func() (net.Listener, chan string) {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		assert.NoError(t, err)
+		lines := make(chan string, 10)
+		go func() {
+			for {
+				c, err := ln.Accept()
+				if err != nil {
+					return
+				}
+				go func() {
+					defer c.Close()
+					scanner := bufio.NewScanner(c)
+					for scanner.Scan() {
+						lines <- scanner.Text()
+					}
+				}()
+			}
+		}()
+		return ln, lines
+	}
+	a, aLines := listen()
+	defer a.Close()
+	b, bLines := listen()
+	defer b.Close()
+
+	tcp := TcpAction{Address: "${host}", Payload: "discount ${discount}", Title: "Send"}
+	state := NewUserState()
+	defer state.Close()
+	for _, tc := range []struct {
+		host  string
+		lines chan string
+	}{{a.Addr().String(), aLines}, {b.Addr().String(), bLines}, {a.Addr().String(), aLines}} {
+		env := &Env{State: state, Session: map[string]string{"host": tc.host, "discount": "50%d"}}
+		assert.NoError(t, tcp.Execute(context.Background(), env))
+		assert.Equal(t, "discount 50%d", <-tc.lines)
+	}
+}
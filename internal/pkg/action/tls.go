@@ -0,0 +1,98 @@
+package action
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/botcliq/loadzy/internal/pkg/testdef"
+	"gopkg.in/yaml.v2"
+)
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+var tlsCiphers = map[string]uint16{
+	"TLS_RSA_WITH_AES_128_CBC_SHA":                  tls.TLS_RSA_WITH_AES_128_CBC_SHA,
+	"TLS_RSA_WITH_AES_256_CBC_SHA":                  tls.TLS_RSA_WITH_AES_256_CBC_SHA,
+	"TLS_RSA_WITH_AES_128_GCM_SHA256":               tls.TLS_RSA_WITH_AES_128_GCM_SHA256,
+	"TLS_RSA_WITH_AES_256_GCM_SHA384":               tls.TLS_RSA_WITH_AES_256_GCM_SHA384,
+	"TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA":          tls.TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA,
+	"TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA":          tls.TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA,
+	"TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA":            tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA,
+	"TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA":            tls.TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA,
+	"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256":         tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+	"TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256":       tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+	"TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384":         tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+	"TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384":       tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+	"TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256":   tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305,
+	"TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256": tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305,
+}
+
+// getTLS returns the TLS settings of an http action: the global ones from the test
+// definition, overridden by those in the action's own tls block.
+func getTLS(action map[interface{}]interface{}, global testdef.TLSConfig) (testdef.TLSConfig, error) {
+	if action["tls"] == nil {
+		return global, nil
+	}
+	raw, err := yaml.Marshal(action["tls"])
+	if err != nil {
+		return global, err
+	}
+	var own testdef.TLSConfig
+	if err := yaml.UnmarshalStrict(raw, &own); err != nil {
+		return global, err
+	}
+	return global.Merge(own), nil
+}
+
+// newTLSConfig turns TLS settings into a crypto/tls configuration, loading the CA
+// bundle and client certificate files they refer to.
+func newTLSConfig(c testdef.TLSConfig) (*tls.Config, error) {
+	cfg := &tls.Config{ServerName: c.ServerName}
+	cfg.InsecureSkipVerify = c.Insecure != nil && *c.Insecure
+	if c.CA != "" {
+		pem, err := ioutil.ReadFile(c.CA)
+		if err != nil {
+			return nil, fmt.Errorf("reading CA bundle: %v", err)
+		}
+		cfg.RootCAs = x509.NewCertPool()
+		if !cfg.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA bundle %s", c.CA)
+		}
+	}
+	if c.Cert != "" || c.Key != "" {
+		if c.Cert == "" || c.Key == "" {
+			return nil, fmt.Errorf("a client certificate needs both cert and key")
+		}
+		cert, err := tls.LoadX509KeyPair(c.Cert, c.Key)
+		if err != nil {
+			return nil, fmt.Errorf("loading client certificate: %v", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	var ok bool
+	if c.MinVersion != "" {
+		if cfg.MinVersion, ok = tlsVersions[c.MinVersion]; !ok {
+			return nil, fmt.Errorf("unknown TLS minVersion '%s', must be 1.0, 1.1, 1.2 or 1.3", c.MinVersion)
+		}
+	}
+	if c.MaxVersion != "" {
+		if cfg.MaxVersion, ok = tlsVersions[c.MaxVersion]; !ok {
+			return nil, fmt.Errorf("unknown TLS maxVersion '%s', must be 1.0, 1.1, 1.2 or 1.3", c.MaxVersion)
+		}
+	}
+	for _, name := range c.Ciphers {
+		id, ok := tlsCiphers[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown TLS cipher '%s'", name)
+		}
+		cfg.CipherSuites = append(cfg.CipherSuites, id)
+	}
+	return cfg, nil
+}
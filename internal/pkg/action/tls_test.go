@@ -0,0 +1,153 @@
+package action
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"log"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/botcliq/loadzy/internal/pkg/testdef"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetTLS_ActionOverridesGlobal(t *testing.T) {
+	insecure := true
+	global := testdef.TLSConfig{CA: "ca.pem", MinVersion: "1.2", Ciphers: []string{"TLS_RSA_WITH_AES_128_GCM_SHA256"}, Insecure: &insecure}
+
+	c, err := getTLS(map[interface{}]interface{}{}, global)
+	assert.NoError(t, err)
+	assert.Equal(t, global, c)
+
+	c, err = getTLS(map[interface{}]interface{}{"tls": map[interface{}]interface{}{
+		"minVersion": "1.3",
+		"serverName": "api.internal",
+		"insecure":   false,
+	}}, global)
+	assert.NoError(t, err)
+	assert.Equal(t, "ca.pem", c.CA)
+	assert.Equal(t, "1.3", c.MinVersion)
+	assert.Equal(t, "api.internal", c.ServerName)
+	assert.Equal(t, global.Ciphers, c.Ciphers)
+	assert.False(t, *c.Insecure)
+	assert.True(t, *global.Insecure)
+
+	_, err = getTLS(map[interface{}]interface{}{"tls": map[interface{}]interface{}{"minVerison": "1.3"}}, global)
+	assert.Error(t, err)
+}
+
+func TestNewTLSConfig_VersionsAndCiphers(t *testing.T) {
+	cfg, err := newTLSConfig(testdef.TLSConfig{
+		MinVersion: "1.2",
+		MaxVersion: "1.3",
+		Ciphers:    []string{"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256", "TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, uint16(tls.VersionTLS12), cfg.MinVersion)
+	assert.Equal(t, uint16(tls.VersionTLS13), cfg.MaxVersion)
+	assert.Equal(t, []uint16{tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256, tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305}, cfg.CipherSuites)
+
+	for _, c := range []testdef.TLSConfig{
+		{MinVersion: "1.4"},
+		{MaxVersion: "TLS1.2"},
+		{Ciphers: []string{"TLS_RSA_WITH_RC4_128_MD5"}},
+		{Cert: "client.pem"},
+		{CA: "missing.pem"},
+	} {
+		_, err := newTLSConfig(c)
+		assert.Error(t, err, "%+v", c)
+	}
+}
+
+func TestNewTLSConfig_VerifiesByDefault(t *testing.T) {
+	cfg, err := newTLSConfig(testdef.TLSConfig{})
+	assert.NoError(t, err)
+	assert.False(t, cfg.InsecureSkipVerify)
+
+	insecure := true
+	cfg, err = newTLSConfig(testdef.TLSConfig{Insecure: &insecure})
+	assert.NoError(t, err)
+	assert.True(t, cfg.InsecureSkipVerify)
+}
+
+func TestNewTLSConfig_CAAndClientCertificate(t *testing.T) {
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(r.TLS.PeerCertificates) == 0 {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	srv.TLS = &tls.Config{ClientAuth: tls.RequestClientCert}
+	srv.Config.ErrorLog = log.New(ioutil.Discard, "", 0)
+	srv.StartTLS()
+	defer srv.Close()
+
+	dir, err := ioutil.TempDir("", "loadzy-tls")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	ca := filepath.Join(dir, "ca.pem")
+	assert.NoError(t, ioutil.WriteFile(ca, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw}), 0600))
+	cert, key := writeClientCertificate(t, dir)
+
+	get := func(c testdef.TLSConfig) (int, error) {
+		cfg, err := newTLSConfig(c)
+		if err != nil {
+			return 0, err
+		}
+		resp, err := (&http.Client{Transport: &http.Transport{TLSClientConfig: cfg}}).Get(srv.URL)
+		if err != nil {
+			return 0, err
+		}
+		resp.Body.Close()
+		return resp.StatusCode, nil
+	}
+
+	_, err = get(testdef.TLSConfig{})
+	assert.Error(t, err, "the test server's certificate is not trusted by default")
+
+	status, err := get(testdef.TLSConfig{CA: ca})
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, status)
+
+	status, err = get(testdef.TLSConfig{CA: ca, Cert: cert, Key: key})
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, status)
+
+	_, err = newTLSConfig(testdef.TLSConfig{CA: cert + ".missing"})
+	assert.Error(t, err)
+	_, err = newTLSConfig(testdef.TLSConfig{CA: key})
+	assert.Error(t, err)
+}
+
+// writeClientCertificate writes a self-signed client certificate and its key to dir
+// and returns their paths.
+func writeClientCertificate(t *testing.T, dir string) (string, string) {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "loadzy"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &priv.PublicKey, priv)
+	assert.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(priv)
+	assert.NoError(t, err)
+
+	cert, key := filepath.Join(dir, "client.pem"), filepath.Join(dir, "client-key.pem")
+	assert.NoError(t, ioutil.WriteFile(cert, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	assert.NoError(t, ioutil.WriteFile(key, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600))
+	return cert, key
+}
@@ -0,0 +1,184 @@
+package action
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/botcliq/loadzy/internal/pkg/testdef"
+	"gopkg.in/yaml.v2"
+)
+
+var transportsMu sync.Mutex
+var transports = make(map[transportKey]*http.Transport)
+
+// transportKey holds the settings a transport is made from. Settings applied per
+// request, like the request timeout, redirects and cookies, are left out so actions
+// differing only in those share a connection pool.
+type transportKey struct {
+	connectTimeout        testdef.Duration
+	tlsTimeout            testdef.Duration
+	responseHeaderTimeout testdef.Duration
+	maxIdleConnsPerHost   int
+	disableKeepAlives     bool
+	tls                   string
+}
+
+func newTransportKey(cfg testdef.HttpConfig, tlsCfg testdef.TLSConfig) transportKey {
+	// Key on the value of Insecure rather than on the pointer.
+	insecure := tlsCfg.Insecure != nil && *tlsCfg.Insecure
+	keyCfg := tlsCfg
+	keyCfg.Insecure = nil
+	return transportKey{
+		connectTimeout:        cfg.ConnectTimeout,
+		tlsTimeout:            cfg.TLSTimeout,
+		responseHeaderTimeout: cfg.ResponseHeaderTimeout,
+		maxIdleConnsPerHost:   cfg.MaxIdleConnsPerHost,
+		disableKeepAlives:     cfg.DisableKeepAlives,
+		tls:                   fmt.Sprintf("%+v|%t", keyCfg, insecure),
+	}
+}
+
+// sharedTransport returns the keep-alive connection pool for http actions using cfg
+// and tlsCfg. All actions with the same transport settings share one transport.
+func sharedTransport(cfg testdef.HttpConfig, tlsCfg testdef.TLSConfig) (*http.Transport, error) {
+	transportsMu.Lock()
+	defer transportsMu.Unlock()
+	key := newTransportKey(cfg, tlsCfg)
+	if tr, ok := transports[key]; ok {
+		return tr, nil
+	}
+	tc, err := newTLSConfig(tlsCfg)
+	if err != nil {
+		return nil, err
+	}
+	tr := &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   time.Duration(cfg.ConnectTimeout),
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		TLSClientConfig:       tc,
+		TLSHandshakeTimeout:   time.Duration(cfg.TLSTimeout),
+		ResponseHeaderTimeout: time.Duration(cfg.ResponseHeaderTimeout),
+		MaxIdleConnsPerHost:   cfg.MaxIdleConnsPerHost,
+		IdleConnTimeout:       90 * time.Second,
+		DisableKeepAlives:     cfg.DisableKeepAlives,
+	}
+	if tr.MaxIdleConnsPerHost == 0 {
+		tr.MaxIdleConnsPerHost = 100
+	}
+	transports[key] = tr
+	return tr, nil
+}
+
+// Used when neither the action nor the test sets a request timeout, so a hung
+// target can't block a worker forever.
+const defaultRequestTimeout = 60 * time.Second
+
+func requestTimeout(t testdef.Timeouts) time.Duration {
+	if t.Timeout > 0 {
+		return time.Duration(t.Timeout)
+	}
+	return defaultRequestTimeout
+}
+
+// getTimeouts returns the timeouts of an http action: the global ones from the test
+// definition, overridden by those set on the action itself.
+func getTimeouts(action map[interface{}]interface{}, global testdef.Timeouts) (testdef.Timeouts, error) {
+	raw, err := yaml.Marshal(action)
+	if err != nil {
+		return global, err
+	}
+	var own testdef.Timeouts
+	if err := yaml.Unmarshal(raw, &own); err != nil {
+		return global, err
+	}
+	return global.Merge(own), nil
+}
+
+// UserState is what a virtual user keeps across the actions and iterations it runs,
+// apart from its session variables.
+type UserState struct {
+	mu         sync.Mutex
+	transports map[*http.Transport]*http.Transport
+	jar        http.CookieJar
+	lastStatus int
+	// conns are the tcp and udp connections of the user, keyed by network and address.
+	conns map[string]net.Conn
+}
+
+func NewUserState() *UserState {
+	return &UserState{transports: make(map[*http.Transport]*http.Transport), jar: newJar(), conns: make(map[string]net.Conn)}
+}
+
+// conn returns the user's connection to address over network, calling dial to make
+// it if the user has none yet.
+func (s *UserState) conn(network, address string, dial func() (net.Conn, error)) (net.Conn, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := network + " " + address
+	if c, ok := s.conns[key]; ok {
+		return c, nil
+	}
+	c, err := dial()
+	if err != nil {
+		return nil, err
+	}
+	s.conns[key] = c
+	return c, nil
+}
+
+// dropConn closes the user's connection to address over network after it failed, so
+// the next action using it dials a new one.
+func (s *UserState) dropConn(network, address string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := network + " " + address
+	if c, ok := s.conns[key]; ok {
+		c.Close()
+		delete(s.conns, key)
+	}
+}
+
+// transportFor returns the user's own clone of a shared transport, so the user's
+// connections are not reused by other users.
+func (s *UserState) transportFor(shared *http.Transport) *http.Transport {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tr, ok := s.transports[shared]
+	if !ok {
+		tr = shared.Clone()
+		s.transports[shared] = tr
+	}
+	return tr
+}
+
+// LastStatus returns the status code of the last http response of the user, 0 if
+// there was none or the request failed.
+func (s *UserState) LastStatus() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lastStatus
+}
+
+func (s *UserState) setLastStatus(status int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastStatus = status
+}
+
+// Close releases the connections held by the user.
+func (s *UserState) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, tr := range s.transports {
+		tr.CloseIdleConnections()
+	}
+	for key, c := range s.conns {
+		c.Close()
+		delete(s.conns, key)
+	}
+}
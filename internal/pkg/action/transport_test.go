@@ -0,0 +1,34 @@
+package action
+
+import (
+	"testing"
+	"time"
+
+	"github.com/botcliq/loadzy/internal/pkg/testdef"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSharedTransport_KeysOnTransportSettings(t *testing.T) {
+	cfg := testdef.HttpConfig{Timeouts: testdef.Timeouts{ConnectTimeout: testdef.Duration(time.Second)}}
+	tr, err := sharedTransport(cfg, testdef.TLSConfig{})
+	assert.NoError(t, err)
+
+	perRequest := cfg
+	perRequest.Timeout = testdef.Duration(5 * time.Second)
+	perRequest.FollowRedirects = testdef.Redirects{Max: 3}
+	perRequest.ConnectionPool = "user"
+	same, err := sharedTransport(perRequest, testdef.TLSConfig{})
+	assert.NoError(t, err)
+	assert.True(t, tr == same)
+
+	other := cfg
+	other.ConnectTimeout = testdef.Duration(2 * time.Second)
+	different, err := sharedTransport(other, testdef.TLSConfig{})
+	assert.NoError(t, err)
+	assert.False(t, tr == different)
+
+	insecure := true
+	different, err = sharedTransport(cfg, testdef.TLSConfig{Insecure: &insecure})
+	assert.NoError(t, err)
+	assert.False(t, tr == different)
+}
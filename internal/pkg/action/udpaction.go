@@ -24,7 +24,8 @@ SOFTWARE.
 package action
 
 import (
-	"github.com/botcliq/loadzy/internal/pkg/result"
+	"context"
+	"log"
 )
 
 type UdpAction struct {
@@ -33,8 +34,8 @@ type UdpAction struct {
 	Title   string `yaml:"title"`
 }
 
-func (t UdpAction) Execute(resultsChannel chan result.HttpReqResult, sessionMap map[string]string) {
-	DoUdpRequest(t, resultsChannel, sessionMap)
+func (t UdpAction) Execute(ctx context.Context, env *Env) error {
+	return DoUdpRequest(t, env)
 }
 
 func NewUdpAction(a map[interface{}]interface{}) UdpAction {
@@ -50,6 +51,9 @@ func NewUdpAction(a map[interface{}]interface{}) UdpAction {
 	if !ok {
 		return UdpAction{}
 	}
+	if !validTemplates("UdpAction", map[string]string{"address": address, "payload": payload}) {
+		log.Fatalf("Your YAML defintion contains an invalid UdpAction, see errors listed above.")
+	}
 	return UdpAction{
 		address,
 		payload,
@@ -25,52 +25,72 @@ package action
 
 import (
 	"fmt"
+	"io"
 	"net"
 	"time"
 
 	"github.com/botcliq/loadzy/internal/pkg/result"
 	"github.com/botcliq/loadzy/internal/pkg/runtime"
+	"github.com/botcliq/loadzy/internal/pkg/stats"
 	"github.com/botcliq/loadzy/internal/pkg/util"
 )
 
-var udpconn *net.UDPConn
+// Accepts a UdpAction and the Env to execute it in. Returns an error if the payload could not be sent.
+func DoUdpRequest(udpAction UdpAction, env *Env) error {
 
-// Accepts a UdpAction and a one-way channel to write the results to.
-func DoUdpRequest(udpAction UdpAction, resultsChannel chan result.HttpReqResult, sessionMap map[string]string) {
-
-	address := util.SubstParams(sessionMap, udpAction.Address)
-	payload := util.SubstParams(sessionMap, udpAction.Payload)
-
-	if udpconn == nil {
-		ServerAddr, err := net.ResolveUDPAddr("udp", address) //"127.0.0.1:10001")
-		if err != nil {
-			fmt.Println("Error ResolveUDPAddr remote: " + err.Error())
-		}
-
-		LocalAddr, err := net.ResolveUDPAddr("udp", "127.0.0.1:0")
-		if err != nil {
-			fmt.Println("Error ResolveUDPAddr local: " + err.Error())
-		}
+	address, err := util.Render(udpAction.Address, env.Session, util.EscapeNone)
+	var payload string
+	if err == nil {
+		payload, err = util.Render(udpAction.Payload, env.Session, util.EscapeNone)
+	}
+	if err != nil {
+		stats.AddError(1, &stats.Result{Title: udpAction.Title, Method: "UDP", URL: udpAction.Address, Error: err.Error(), Scenario: env.Scenario})
+		return err
+	}
 
-		udpconn, err = net.DialUDP("udp", LocalAddr, ServerAddr)
-		if err != nil {
-			fmt.Println("Error Dial: " + err.Error())
-		}
+	// Every user keeps its own connection per address, outside of a user the
+	// connection only lasts for this request.
+	state := env.State
+	if state == nil {
+		state = NewUserState()
+		defer state.Close()
 	}
-	//defer Conn.Close()
+	udpconn, err := state.conn("udp", address, func() (net.Conn, error) { return dialUdp(address) })
 	start := time.Now()
 
 	if udpconn != nil {
-		_, err := fmt.Fprintf(udpconn, payload+"\r\n")
+		_, err = io.WriteString(udpconn, payload+"\r\n")
 		if err != nil {
 			fmt.Printf("UDP request failed with error: %s\n", err)
-			udpconn = nil
+			state.dropConn("udp", address)
 		}
 	}
+	if err != nil {
+		stats.AddError(1, &stats.Result{Title: udpAction.Title, Method: "UDP", URL: address, Error: err.Error(), Scenario: env.Scenario})
+	}
 
 	elapsed := time.Since(start)
-	resultsChannel <- buildUdpResult(0, 200, elapsed.Nanoseconds(), udpAction.Title)
+	env.report(buildUdpResult(0, 200, elapsed.Nanoseconds(), udpAction.Title))
+	return err
+}
 
+func dialUdp(address string) (net.Conn, error) {
+	ServerAddr, err := net.ResolveUDPAddr("udp", address) //"127.0.0.1:10001")
+	if err != nil {
+		fmt.Println("Error ResolveUDPAddr remote: " + err.Error())
+		return nil, err
+	}
+	LocalAddr, err := net.ResolveUDPAddr("udp", "127.0.0.1:0")
+	if err != nil {
+		fmt.Println("Error ResolveUDPAddr local: " + err.Error())
+		return nil, err
+	}
+	conn, err := net.DialUDP("udp", LocalAddr, ServerAddr)
+	if err != nil {
+		fmt.Println("Error Dial: " + err.Error())
+		return nil, err
+	}
+	return conn, nil
 }
 
 func buildUdpResult(contentLength int, status int, elapsed int64, title string) result.HttpReqResult {
@@ -43,24 +43,32 @@ var FeedChannel chan map[string]string
 //
 func NextFromFeeder() {
 
-	if data != nil && len(data) > 0 {
-
-		// Push data into the FeedChannel
-		// fmt.Printf("Current index: %d of total size: %d\n", index, len(data))
-		FeedChannel <- data[index]
-
-		// Cycle, does this need to be synchronized?
-		l.Lock()
-		if index < len(data)-1 {
-			index += 1
-		} else {
-			index = 0
-		}
-		l.Unlock()
+	if item := Next(); item != nil {
+		FeedChannel <- item
 	}
 
 }
 
+// Next returns a copy of the next item of feeder data, cycling through it, or nil if
+// there is no data. It is safe for concurrent use by many users.
+func Next() map[string]string {
+	l.Lock()
+	defer l.Unlock()
+	if len(data) == 0 {
+		return nil
+	}
+	item := make(map[string]string, len(data[index]))
+	for k, v := range data[index] {
+		item[k] = v
+	}
+	if index < len(data)-1 {
+		index += 1
+	} else {
+		index = 0
+	}
+	return item
+}
+
 func Csv(filename string, separator string) {
 	dir, _ := os.Getwd()
 	file, _ := os.Open(dir + "/data/" + filename)
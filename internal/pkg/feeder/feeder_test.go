@@ -0,0 +1,33 @@
+package feeder
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNext_Concurrent(t *testing.T) {
+	data = []map[string]string{{"name": "a"}, {"name": "b"}}
+	index = 0
+
+	var mu sync.Mutex
+	counts := map[string]int{}
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			item := Next()
+			item["name"] += "!" // a copy, the feeder data is unchanged
+			mu.Lock()
+			counts[item["name"]]++
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, map[string]int{"a!": 5, "b!": 5}, counts)
+
+	data = nil
+	assert.Nil(t, Next())
+}
@@ -58,15 +58,22 @@ func aggregatePerSecondHandler(perSecondChannel chan *HttpReqResult) {
 
 }
 
+// PoolStats, when set, returns the size of the worker pool, its busy workers and the
+// tasks queued up waiting for one, sent along with every frame to show saturation.
+var PoolStats func() (workers, busy, queued int)
+
 func assembleAndSendResult(totalReq int, totalLatency int) {
 	avgLatency := 0
 	if totalReq > 0 {
 		avgLatency = totalLatency / totalReq
 	}
 	statFrame := StatFrame{
-		time.Since(runtime.SimulationStart).Nanoseconds() / 1000000000, // seconds
-		avgLatency, // microseconds
-		totalReq,
+		Time:    time.Since(runtime.SimulationStart).Nanoseconds() / 1000000000, // seconds
+		Latency: avgLatency,                                                     // microseconds
+		Reqs:    totalReq,
+	}
+	if PoolStats != nil {
+		statFrame.Workers, statFrame.Busy, statFrame.Queued = PoolStats()
 	}
 	// fmt.Printf("Time: %d Avg latency: %d μs (%d ms) req/s: %d\n", statFrame.Time, statFrame.Latency, statFrame.Latency/1000, statFrame.Reqs)
 
@@ -27,6 +27,8 @@ import (
 	"bufio"
 	"encoding/json"
 	"os"
+	"path/filepath"
+	"sync"
 )
 
 var w *bufio.Writer
@@ -35,6 +37,11 @@ var err error
 
 var opened bool = false
 
+// closed is set once the file is closed, results arriving after that are dropped so
+// the file stays valid. mu guards it and the writer.
+var closed bool = false
+var mu sync.Mutex
+
 func OpenResultsFile(fileName string) {
 	if !opened {
 		opened = true
@@ -43,8 +50,7 @@ func OpenResultsFile(fileName string) {
 	}
 	f, err = os.Create(fileName)
 	if err != nil {
-		os.Mkdir("results", 0777)
-		os.Mkdir("results/log", 0777)
+		os.MkdirAll(filepath.Dir(fileName), 0777)
 		f, err = os.Create(fileName)
 		if err != nil {
 			panic(err)
@@ -55,7 +61,10 @@ func OpenResultsFile(fileName string) {
 }
 
 func CloseResultsFile() {
-	if opened {
+	mu.Lock()
+	defer mu.Unlock()
+	if opened && !closed {
+		closed = true
 		_, err = w.WriteString(string("';"))
 		w.Flush()
 		f.Close()
@@ -68,6 +77,11 @@ func writeResult(httpResult *HttpReqResult) {
 	if err != nil {
 		panic(err)
 	}
+	mu.Lock()
+	defer mu.Unlock()
+	if !opened || closed {
+		return
+	}
 	_, err = w.WriteString(string(jsonString))
 	_, err = w.WriteString("|")
 
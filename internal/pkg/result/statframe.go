@@ -27,4 +27,7 @@ type StatFrame struct {
 	Time    int64 `json:"time"`
 	Latency int   `json:"latency"`
 	Reqs    int   `json:"reqs"`
+	Workers int   `json:"workers"`
+	Busy    int   `json:"busy"`
+	Queued  int   `json:"queued"`
 }
@@ -0,0 +1,49 @@
+package scheduler
+
+import (
+	"context"
+	"math/rand"
+	"time"
+
+	"github.com/botcliq/loadzy/internal/pkg/testdef"
+)
+
+// Interarrival returns how long to wait before starting the next iteration when
+// iterations arrive at rate per second: evenly spaced for a constant arrival rate,
+// exponentially distributed for a Poisson arrival rate.
+func Interarrival(e testdef.Executor, rnd *rand.Rand) time.Duration {
+	mean := float64(time.Second) / e.Rate
+	if e.Type == testdef.POISSON_ARRIVAL_RATE {
+		return time.Duration(rnd.ExpFloat64() * mean)
+	}
+	return time.Duration(mean)
+}
+
+// RunArrivals starts iterations at the arrival rate of e until deadline, if not zero,
+// or until ctx is cancelled or iterations have been started, if not 0. At every
+// arrival it calls start, which returns false if there was no idle user to start the
+// iteration on. Such an iteration is dropped rather than delayed, so a slow target
+// does not lower the offered load; RunArrivals returns how many were dropped.
+func RunArrivals(ctx context.Context, e testdef.Executor, iterations int, deadline time.Time, rnd *rand.Rand, start func() bool) int {
+	dropped := 0
+	next := time.Now()
+	for started := 0; iterations == 0 || started < iterations; {
+		next = next.Add(Interarrival(e, rnd))
+		if !deadline.IsZero() && !next.Before(deadline) {
+			break
+		}
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return dropped
+		}
+		if start() {
+			started++
+		} else {
+			dropped++
+		}
+	}
+	return dropped
+}
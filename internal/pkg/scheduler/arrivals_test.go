@@ -0,0 +1,75 @@
+package scheduler
+
+import (
+	"context"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/botcliq/loadzy/internal/pkg/testdef"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInterarrival(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	constant := testdef.Executor{Type: testdef.CONSTANT_ARRIVAL_RATE, Rate: 50}
+	assert.Equal(t, 20*time.Millisecond, Interarrival(constant, rnd))
+
+	poisson := testdef.Executor{Type: testdef.POISSON_ARRIVAL_RATE, Rate: 50}
+	var total time.Duration
+	distinct := map[time.Duration]bool{}
+	const n = 10000
+	for i := 0; i < n; i++ {
+		d := Interarrival(poisson, rnd)
+		assert.True(t, d >= 0)
+		total += d
+		distinct[d] = true
+	}
+	assert.InDelta(t, float64(20*time.Millisecond), float64(total/n), float64(time.Millisecond))
+	assert.True(t, len(distinct) > n/2)
+}
+
+func TestRunArrivals_StartsAtRate(t *testing.T) {
+	e := testdef.Executor{Type: testdef.CONSTANT_ARRIVAL_RATE, Rate: 100}
+	var starts []time.Time
+	begin := time.Now()
+	dropped := RunArrivals(context.Background(), e, 10, time.Time{}, rand.New(rand.NewSource(1)), func() bool {
+		starts = append(starts, time.Now())
+		return true
+	})
+	assert.Equal(t, 0, dropped)
+	assert.Len(t, starts, 10)
+	assert.True(t, starts[9].Sub(begin) >= 100*time.Millisecond, starts[9].Sub(begin))
+	assert.True(t, starts[9].Sub(begin) < 300*time.Millisecond, starts[9].Sub(begin))
+
+	starts = nil
+	e.Rate = 20
+	RunArrivals(context.Background(), e, 0, time.Now().Add(275*time.Millisecond), rand.New(rand.NewSource(1)), func() bool {
+		starts = append(starts, time.Now())
+		return true
+	})
+	assert.Len(t, starts, 5)
+}
+
+func TestRunArrivals_DropsWhenNoUserIsIdle(t *testing.T) {
+	e := testdef.Executor{Type: testdef.CONSTANT_ARRIVAL_RATE, Rate: 1000}
+	calls := 0
+	dropped := RunArrivals(context.Background(), e, 5, time.Time{}, rand.New(rand.NewSource(1)), func() bool {
+		calls++
+		return calls%2 == 0
+	})
+	assert.Equal(t, 5, dropped)
+	assert.Equal(t, 10, calls)
+}
+
+func TestRunArrivals_StopsWhenCancelled(t *testing.T) {
+	e := testdef.Executor{Type: testdef.CONSTANT_ARRIVAL_RATE, Rate: 1000}
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := 0
+	RunArrivals(ctx, e, 0, time.Time{}, rand.New(rand.NewSource(1)), func() bool {
+		calls++
+		cancel()
+		return true
+	})
+	assert.Equal(t, 1, calls)
+}
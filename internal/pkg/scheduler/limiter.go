@@ -0,0 +1,53 @@
+package scheduler
+
+import (
+	"sync"
+	"time"
+
+	"go.uber.org/ratelimit"
+)
+
+// RateLimiter is a rate limiter whose rate can be changed while it is in use.
+// A rate of 0 means unlimited.
+type RateLimiter struct {
+	mu   sync.RWMutex
+	rate int
+	rl   ratelimit.Limiter
+}
+
+// NewRateLimiter returns a RateLimiter allowing rate calls to Take per second.
+func NewRateLimiter(rate int) *RateLimiter {
+	r := &RateLimiter{}
+	r.SetRate(rate)
+	return r
+}
+
+// Take blocks until the current rate allows another request through.
+func (r *RateLimiter) Take() time.Time {
+	r.mu.RLock()
+	rl := r.rl
+	r.mu.RUnlock()
+	return rl.Take()
+}
+
+// SetRate retunes the limiter. Callers blocked in Take finish at the old rate.
+func (r *RateLimiter) SetRate(rate int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.rl != nil && rate == r.rate {
+		return
+	}
+	r.rate = rate
+	if rate > 0 {
+		r.rl = ratelimit.New(rate)
+	} else {
+		r.rl = ratelimit.NewUnlimited()
+	}
+}
+
+// Rate returns the current rate, 0 meaning unlimited.
+func (r *RateLimiter) Rate() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.rate
+}
@@ -0,0 +1,55 @@
+package scheduler
+
+import (
+	"math"
+	"time"
+
+	"github.com/botcliq/loadzy/internal/pkg/testdef"
+)
+
+// Target is the number of users and the request rate a load profile asks for.
+type Target struct {
+	Users int
+	Rate  int
+}
+
+// At returns the target of stages at elapsed time since the start of the first
+// stage, interpolating linearly within a stage and starting from initial. A rate of 0
+// is unlimited, so a stage ramping from or to it holds the other rate instead. The
+// second return value is false once elapsed is past the end of the last stage.
+func At(stages []testdef.Stage, initial Target, elapsed time.Duration) (Target, bool) {
+	from := initial
+	for _, s := range stages {
+		to := from
+		if s.Users != nil {
+			to.Users = *s.Users
+		}
+		if s.Rate != nil {
+			to.Rate = *s.Rate
+		}
+		d := time.Duration(s.Duration)
+		if elapsed < d {
+			f := float64(elapsed) / float64(d)
+			return Target{Users: lerp(from.Users, to.Users, f), Rate: lerpRate(from.Rate, to.Rate, f)}, true
+		}
+		elapsed -= d
+		from = to
+	}
+	return from, false
+}
+
+func lerp(from, to int, f float64) int {
+	return from + int(math.Round(float64(to-from)*f))
+}
+
+// lerpRate interpolates between two rates, unless one of them is unlimited: there
+// is no rate halfway to unlimited, so the limited one holds for the whole stage.
+func lerpRate(from, to int, f float64) int {
+	if from == 0 {
+		return to
+	}
+	if to == 0 {
+		return from
+	}
+	return lerp(from, to, f)
+}
@@ -0,0 +1,64 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/botcliq/loadzy/internal/pkg/testdef"
+	"github.com/stretchr/testify/assert"
+)
+
+func intp(i int) *int { return &i }
+
+func TestAt_InterpolatesBetweenStages(t *testing.T) {
+	stages := []testdef.Stage{
+		{Duration: testdef.Duration(10 * time.Second), Users: intp(100), Rate: intp(50)},
+		{Duration: testdef.Duration(20 * time.Second), Users: intp(100)},
+		{Duration: testdef.Duration(10 * time.Second), Users: intp(0), Rate: intp(10)},
+	}
+	initial := Target{Users: 0, Rate: 10}
+
+	target, running := At(stages, initial, 5*time.Second)
+	assert.True(t, running)
+	assert.Equal(t, Target{Users: 50, Rate: 30}, target)
+
+	target, _ = At(stages, initial, 25*time.Second)
+	assert.Equal(t, Target{Users: 100, Rate: 50}, target)
+
+	target, _ = At(stages, initial, 35*time.Second)
+	assert.Equal(t, Target{Users: 50, Rate: 30}, target)
+
+	target, running = At(stages, initial, 41*time.Second)
+	assert.False(t, running)
+	assert.Equal(t, Target{Users: 0, Rate: 10}, target)
+
+	// A rate of 0 is unlimited, so ramps from or to it hold the limited rate.
+	stages = []testdef.Stage{
+		{Duration: testdef.Duration(10 * time.Second), Rate: intp(200)},
+		{Duration: testdef.Duration(10 * time.Second), Rate: intp(0)},
+	}
+	initial = Target{Users: 10, Rate: 0}
+	for _, elapsed := range []time.Duration{0, 300 * time.Millisecond, 5 * time.Second, 15 * time.Second, 19 * time.Second} {
+		target, running = At(stages, initial, elapsed)
+		assert.True(t, running)
+		assert.Equal(t, Target{Users: 10, Rate: 200}, target, elapsed)
+	}
+	target, running = At(stages, initial, 21*time.Second)
+	assert.False(t, running)
+	assert.Equal(t, Target{Users: 10, Rate: 0}, target)
+}
+
+func TestAt_RateOnlyStagesKeepInitialUsers(t *testing.T) {
+	stages := []testdef.Stage{
+		{Duration: testdef.Duration(10 * time.Second), Rate: intp(50)},
+		{Duration: testdef.Duration(10 * time.Second), Rate: intp(10)},
+	}
+	initial := Target{Users: 10, Rate: 0}
+
+	target, running := At(stages, initial, 5*time.Second)
+	assert.True(t, running)
+	assert.Equal(t, Target{Users: 10, Rate: 50}, target)
+
+	target, _ = At(stages, initial, 15*time.Second)
+	assert.Equal(t, Target{Users: 10, Rate: 30}, target)
+}
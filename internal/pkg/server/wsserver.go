@@ -27,14 +27,11 @@ import (
 	//	"time"
 	"fmt"
 	//	"math/rand"
-	"flag"
 	"github.com/gorilla/websocket"
 	"log"
 	"net/http"
 )
 
-var addr = flag.String("addr", "localhost:8182", "http service address")
-
 var upgrader = websocket.Upgrader{} // use default options
 
 func Remove(item int) {
@@ -74,18 +71,18 @@ func registerChannel(w http.ResponseWriter, r *http.Request) {
 
 }
 
-func StartWsServer() {
-	fmt.Println("Starting WebSocket server")
-	flag.Parse()
-	log.SetFlags(0)
+// StartWsServer serves the live dashboard and its WebSocket stats feed on addr,
+// e.g. localhost:8182.
+func StartWsServer(addr string) {
+	log.Println("Starting WebSocket server on " + addr)
 
 	http.HandleFunc("/start", registerChannel)
 	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		http.ServeFile(w, r, "static/"+r.URL.Path[1:])
 	})
-	err := http.ListenAndServe(*addr, nil)
+	err := http.ListenAndServe(addr, nil)
 	if err != nil {
 		panic("ListenAndServe: " + err.Error())
 	}
-	fmt.Println("Started WebSocket server")
+	log.Println("Started WebSocket server")
 }
@@ -39,6 +39,8 @@ func easyjsonBd1621b8DecodeGithubComTsenartVegetaV12Lib(in *jlexer.Lexer, out *j
 		switch key {
 		case "attack":
 			out.Attack = string(in.String())
+		case "title":
+			out.Title = string(in.String())
 		case "seq":
 			out.Seq = uint64(in.Uint64())
 		case "code":
@@ -49,6 +51,10 @@ func easyjsonBd1621b8DecodeGithubComTsenartVegetaV12Lib(in *jlexer.Lexer, out *j
 			}
 		case "latency":
 			out.Latency = time.Duration(in.Int64())
+		case "response_time":
+			out.ResponseTime = time.Duration(in.Int64())
+		case "timings":
+			easyjsonBd1621b8DecodeTimings(in, &out.Timings)
 		case "bytes_out":
 			out.BytesOut = uint64(in.Uint64())
 		case "bytes_in":
@@ -66,6 +72,12 @@ func easyjsonBd1621b8DecodeGithubComTsenartVegetaV12Lib(in *jlexer.Lexer, out *j
 			out.Method = string(in.String())
 		case "url":
 			out.URL = string(in.String())
+		case "status":
+			out.Status = int(in.Int())
+		case "hop":
+			out.Hop = int(in.Int())
+		case "scenario":
+			out.Scenario = string(in.String())
 		case "headers":
 			if in.IsNull() {
 				in.Skip()
@@ -126,6 +138,11 @@ func easyjsonBd1621b8EncodeGithubComTsenartVegetaV12Lib(out *jwriter.Writer, in
 		out.RawString(prefix[1:])
 		out.String(string(in.Attack))
 	}
+	{
+		const prefix string = ",\"title\":"
+		out.RawString(prefix)
+		out.String(string(in.Title))
+	}
 	{
 		const prefix string = ",\"seq\":"
 		out.RawString(prefix)
@@ -146,6 +163,16 @@ func easyjsonBd1621b8EncodeGithubComTsenartVegetaV12Lib(out *jwriter.Writer, in
 		out.RawString(prefix)
 		out.Int64(int64(in.Latency))
 	}
+	{
+		const prefix string = ",\"response_time\":"
+		out.RawString(prefix)
+		out.Int64(int64(in.ResponseTime))
+	}
+	{
+		const prefix string = ",\"timings\":"
+		out.RawString(prefix)
+		easyjsonBd1621b8EncodeTimings(out, in.Timings)
+	}
 	{
 		const prefix string = ",\"bytes_out\":"
 		out.RawString(prefix)
@@ -176,6 +203,21 @@ func easyjsonBd1621b8EncodeGithubComTsenartVegetaV12Lib(out *jwriter.Writer, in
 		out.RawString(prefix)
 		out.String(string(in.URL))
 	}
+	{
+		const prefix string = ",\"status\":"
+		out.RawString(prefix)
+		out.Int(int(in.Status))
+	}
+	{
+		const prefix string = ",\"hop\":"
+		out.RawString(prefix)
+		out.Int(int(in.Hop))
+	}
+	{
+		const prefix string = ",\"scenario\":"
+		out.RawString(prefix)
+		out.String(string(in.Scenario))
+	}
 	{
 		const prefix string = ",\"headers\":"
 		out.RawString(prefix)
@@ -211,6 +253,75 @@ func easyjsonBd1621b8EncodeGithubComTsenartVegetaV12Lib(out *jwriter.Writer, in
 	out.RawByte('}')
 }
 
+func easyjsonBd1621b8DecodeTimings(in *jlexer.Lexer, out *Timings) {
+	isTopLevel := in.IsStart()
+	if in.IsNull() {
+		if isTopLevel {
+			in.Consumed()
+		}
+		in.Skip()
+		return
+	}
+	in.Delim('{')
+	for !in.IsDelim('}') {
+		key := in.UnsafeString()
+		in.WantColon()
+		if in.IsNull() {
+			in.Skip()
+			in.WantComma()
+			continue
+		}
+		switch key {
+		case "dns":
+			out.DNS = time.Duration(in.Int64())
+		case "connect":
+			out.Connect = time.Duration(in.Int64())
+		case "tls_handshake":
+			out.TLSHandshake = time.Duration(in.Int64())
+		case "first_byte":
+			out.FirstByte = time.Duration(in.Int64())
+		case "transfer":
+			out.Transfer = time.Duration(in.Int64())
+		default:
+			in.SkipRecursive()
+		}
+		in.WantComma()
+	}
+	in.Delim('}')
+	if isTopLevel {
+		in.Consumed()
+	}
+}
+func easyjsonBd1621b8EncodeTimings(out *jwriter.Writer, in Timings) {
+	out.RawByte('{')
+	{
+		const prefix string = ",\"dns\":"
+		out.RawString(prefix[1:])
+		out.Int64(int64(in.DNS))
+	}
+	{
+		const prefix string = ",\"connect\":"
+		out.RawString(prefix)
+		out.Int64(int64(in.Connect))
+	}
+	{
+		const prefix string = ",\"tls_handshake\":"
+		out.RawString(prefix)
+		out.Int64(int64(in.TLSHandshake))
+	}
+	{
+		const prefix string = ",\"first_byte\":"
+		out.RawString(prefix)
+		out.Int64(int64(in.FirstByte))
+	}
+	{
+		const prefix string = ",\"transfer\":"
+		out.RawString(prefix)
+		out.Int64(int64(in.Transfer))
+	}
+	out.RawByte('}')
+}
+
 // MarshalEasyJSON supports easyjson.Marshaler interface
 func (v jsonResult) MarshalEasyJSON(w *jwriter.Writer) {
 	easyjsonBd1621b8EncodeGithubComTsenartVegetaV12Lib(w, v)
@@ -0,0 +1,89 @@
+package stats
+
+import "strconv"
+
+const (
+	// GroupByTitle groups results by the title of the action that produced them.
+	GroupByTitle = "title"
+	// GroupByRequest groups results by request method and url pattern.
+	GroupByRequest = "request"
+)
+
+var groupBy = GroupByTitle
+
+// SetGroupBy sets how results are broken down into groups, GroupByTitle or GroupByRequest.
+func SetGroupBy(by string) {
+	mutex.Lock()
+	groupBy = by
+	mutex.Unlock()
+}
+
+// groupName returns the group r belongs to. Results without a title are grouped
+// by request.
+func groupName(r *Result) string {
+	if groupBy == GroupByTitle && r.Title != "" {
+		return r.Title
+	}
+	return r.Method + " " + r.URL
+}
+
+// GroupMetrics holds the metrics of one group of results, e.g. of all the requests
+// of an action.
+type GroupMetrics struct {
+	// Requests is the number of results in the group.
+	Requests uint64 `json:"requests"`
+	// Latencies holds computed request latency metrics.
+	Latencies LatencyMetrics `json:"latencies"`
+	// ResponseTimes holds coordinated-omission corrected latency metrics.
+	ResponseTimes LatencyMetrics `json:"response_times"`
+	// Success is the percentage of non-error responses.
+	Success float64 `json:"success"`
+	// StatusCodes is a histogram of the responses' status codes.
+	StatusCodes map[string]int `json:"status_codes"`
+	// Errors is a set of unique errors of the group's requests.
+	Errors []string `json:"errors"`
+
+	errors  map[string]struct{}
+	success uint64
+}
+
+func newGroupMetrics() *GroupMetrics {
+	return &GroupMetrics{
+		StatusCodes: map[string]int{},
+		Errors:      make([]string, 0),
+		errors:      map[string]struct{}{},
+	}
+}
+
+func (g *GroupMetrics) add(r *Result) {
+	g.Requests++
+	g.StatusCodes[strconv.Itoa(r.Status)]++
+	g.Latencies.Add(r.Latency)
+	if r.ResponseTime > 0 {
+		g.ResponseTimes.Add(r.ResponseTime)
+	} else {
+		g.ResponseTimes.Add(r.Latency)
+	}
+	if r.Status < 300 && r.Status >= 200 || r.Hop > 0 && r.Status < 400 && r.Status >= 300 {
+		g.success++
+	}
+	g.addError(r.Error)
+}
+
+func (g *GroupMetrics) addError(e string) {
+	if e != "" {
+		if _, ok := g.errors[e]; !ok {
+			g.errors[e] = struct{}{}
+			g.Errors = append(g.Errors, e)
+		}
+	}
+}
+
+func (g *GroupMetrics) close() {
+	if g.Requests == 0 {
+		return
+	}
+	g.Success = float64(g.success) / float64(g.Requests)
+	g.Latencies.close(g.Requests)
+	g.ResponseTimes.close(g.Requests)
+}
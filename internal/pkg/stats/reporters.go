@@ -59,10 +59,10 @@ func NewTextReporter(m *Metrics) Reporter {
 	const fmtstr = "Requests\t[total, rate, throughput]\t%d, %.2f, %.2f\n" +
 		"Duration\t[total, attack, wait]\t%s, %s, %s\n" +
 		"Latencies\t[min, mean, 50, 90, 95, 99, max]\t%s, %s, %s, %s, %s, %s, %s\n" +
+		"Response Times\t[min, mean, 50, 90, 95, 99, max]\t%s, %s, %s, %s, %s, %s, %s\n" +
 		"Bytes In\t[total, mean]\t%d, %.2f\n" +
 		"Bytes Out\t[total, mean]\t%d, %.2f\n" +
-		"Success\t[ratio]\t%.2f%%\n" +
-		"Status Codes\t[code:count]\t\n"
+		"Success\t[ratio]\t%.2f%%\n"
 
 	return func(w io.Writer) (err error) {
 		mutex.Lock()
@@ -79,6 +79,13 @@ func NewTextReporter(m *Metrics) Reporter {
 			round(m.Latencies.P95),
 			round(m.Latencies.P99),
 			round(m.Latencies.Max),
+			round(m.ResponseTimes.Min),
+			round(m.ResponseTimes.Mean),
+			round(m.ResponseTimes.P50),
+			round(m.ResponseTimes.P90),
+			round(m.ResponseTimes.P95),
+			round(m.ResponseTimes.P99),
+			round(m.ResponseTimes.Max),
 			m.BytesIn.Total, m.BytesIn.Mean,
 			m.BytesOut.Total, m.BytesOut.Mean,
 			m.Success*100,
@@ -86,6 +93,27 @@ func NewTextReporter(m *Metrics) Reporter {
 			return err
 		}
 
+		phases := []string{"DNS", "Connect", "TLS Handshake", "First Byte", "Transfer"}
+		for i, p := range m.Phases.list() {
+			if p.Count == 0 {
+				continue
+			}
+			if _, err = fmt.Fprintf(tw, "  %s\t[count, mean, 50, 95, 99, max]\t%d, %s, %s, %s, %s, %s\n",
+				phases[i], p.Count, round(p.Mean), round(p.P50), round(p.P95), round(p.P99), round(p.Max)); err != nil {
+				return err
+			}
+		}
+
+		if m.DroppedIterations > 0 {
+			if _, err = fmt.Fprintf(tw, "Iterations\t[dropped]\t%d\n", m.DroppedIterations); err != nil {
+				return err
+			}
+		}
+
+		if _, err = fmt.Fprintf(tw, "Status Codes\t[code:count]\t\n"); err != nil {
+			return err
+		}
+
 		codes := make([]string, 0, len(m.StatusCodes))
 		for code := range m.StatusCodes {
 			codes = append(codes, code)
@@ -109,6 +137,40 @@ func NewTextReporter(m *Metrics) Reporter {
 				return err
 			}
 		}
+
+		if len(m.Scenarios) > 0 {
+			if err = writeGroups(tw, "By Scenario", m.Scenarios); err != nil {
+				return err
+			}
+		}
+
+		if len(m.Groups) > 0 {
+			header := "By Title"
+			if groupBy == GroupByRequest {
+				header = "By Request"
+			}
+			if err = writeGroups(tw, header, m.Groups); err != nil {
+				return err
+			}
+		}
+
+		if len(m.Checks) > 0 {
+			names := make([]string, 0, len(m.Checks))
+			for name := range m.Checks {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+
+			if _, err = fmt.Fprintln(tw, "Checks\t[passed, failed]\t"); err != nil {
+				return err
+			}
+			for _, name := range names {
+				c := m.Checks[name]
+				if _, err = fmt.Fprintf(tw, "  %s\t%d, %d\t\n", name, c.Passes, c.Fails); err != nil {
+					return err
+				}
+			}
+		}
 		fmt.Fprintln(tw, "Slowest responses:\t")
 		for i := len(m.Slowest) - 1; i >= 0; i-- {
 			if m.Slowest[i].Latency > 0*time.Second {
@@ -140,6 +202,42 @@ func round(d time.Duration) time.Duration {
 	return d
 }
 
+// writeGroups writes the per group metrics as a table under header, followed by the
+// errors of each group.
+func writeGroups(tw io.Writer, header string, groups map[string]*GroupMetrics) (err error) {
+	names := make([]string, 0, len(groups))
+	for name := range groups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	if _, err = fmt.Fprintf(tw, "%s\t[requests, success, min, mean, 50, 90, 95, 99, max]\t[code:count]\n", header); err != nil {
+		return err
+	}
+	for _, name := range names {
+		g := groups[name]
+		l := g.Latencies
+		codes := make([]string, 0, len(g.StatusCodes))
+		for code, count := range g.StatusCodes {
+			codes = append(codes, fmt.Sprintf("%s:%d", code, count))
+		}
+		sort.Strings(codes)
+		if _, err = fmt.Fprintf(tw, "  %s\t%d, %.2f%%, %s, %s, %s, %s, %s, %s, %s\t%s\n",
+			name, g.Requests, g.Success*100, round(l.Min), round(l.Mean), round(l.P50), round(l.P90),
+			round(l.P95), round(l.P99), round(l.Max), strings.Join(codes, " ")); err != nil {
+			return err
+		}
+	}
+	for _, name := range names {
+		for _, e := range groups[name].Errors {
+			if _, err = fmt.Fprintf(tw, "  %s: %s\n", name, e); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
 // NewJSONReporter returns a Reporter that writes out Metrics as JSON.
 func NewJSONReporter(m *Metrics) Reporter {
 	return func(w io.Writer) error {
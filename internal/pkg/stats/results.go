@@ -24,19 +24,45 @@ func init() {
 
 // Result contains the results of a single Target hit.
 type Result struct {
-	Attack    string        `json:"attack"`
+	Attack string `json:"attack"`
+	// Title is the title of the action the Result belongs to.
+	Title     string        `json:"title"`
 	Seq       uint64        `json:"seq"`
 	Code      string        `json:"code"`
 	Timestamp time.Time     `json:"timestamp"`
 	Latency   time.Duration `json:"latency"`
-	BytesOut  uint64        `json:"bytes_out"`
-	BytesIn   uint64        `json:"bytes_in"`
-	Error     string        `json:"error"`
-	Body      []byte        `json:"body"`
-	Method    string        `json:"method"`
-	URL       string        `json:"url"`
-	Headers   http.Header   `json:"headers"`
-	Status    int           `json:"status"`
+	// ResponseTime is the time from when the request was scheduled to be sent until
+	// the response arrived, so unlike Latency it includes any queueing before sending.
+	ResponseTime time.Duration `json:"response_time"`
+	// Timings breaks the Latency of an http request down into its phases.
+	Timings  Timings     `json:"timings"`
+	BytesOut uint64      `json:"bytes_out"`
+	BytesIn  uint64      `json:"bytes_in"`
+	Error    string      `json:"error"`
+	Body     []byte      `json:"body"`
+	Method   string      `json:"method"`
+	URL      string      `json:"url"`
+	Headers  http.Header `json:"headers"`
+	Status   int         `json:"status"`
+	// Hop numbers a redirect response received while following redirects, when hops
+	// are recorded. It only counts towards the group of its own, not the totals.
+	Hop int `json:"hop"`
+	// Scenario is the name of the scenario of the user the Result belongs to, if the
+	// test has scenarios.
+	Scenario string `json:"scenario"`
+}
+
+// Timings holds how long each phase of an http request took. Phases that did not
+// happen, e.g. DNS and connecting on a reused connection, are zero.
+type Timings struct {
+	DNS          time.Duration `json:"dns"`
+	Connect      time.Duration `json:"connect"`
+	TLSHandshake time.Duration `json:"tls_handshake"`
+	// FirstByte is the time from having written the request until the first response
+	// byte, i.e. how long the server took to answer.
+	FirstByte time.Duration `json:"first_byte"`
+	// Transfer is the time from the first response byte until the body was read.
+	Transfer time.Duration `json:"transfer"`
 }
 
 // End returns the time at which a Result ended.
@@ -45,16 +71,21 @@ func (r *Result) End() time.Time { return r.Timestamp.Add(r.Latency) }
 // Equal returns true if the given Result is equal to the receiver.
 func (r Result) Equal(other Result) bool {
 	return r.Attack == other.Attack &&
+		r.Title == other.Title &&
 		r.Seq == other.Seq &&
 		r.Code == other.Code &&
 		r.Timestamp.Equal(other.Timestamp) &&
 		r.Latency == other.Latency &&
+		r.ResponseTime == other.ResponseTime &&
+		r.Timings == other.Timings &&
 		r.BytesIn == other.BytesIn &&
 		r.BytesOut == other.BytesOut &&
 		r.Error == other.Error &&
 		bytes.Equal(r.Body, other.Body) &&
 		r.Method == other.Method &&
 		r.URL == other.URL &&
+		r.Hop == other.Hop &&
+		r.Scenario == other.Scenario &&
 		headerEqual(r.Headers, other.Headers)
 }
 
@@ -168,7 +199,10 @@ func (enc Encoder) Encode(r *Result) error { return enc(r) }
 // NewCSVEncoder returns an Encoder that dumps the given *Result as a CSV
 // record. The columns are: UNIX timestamp in ns since epoch,
 // HTTP status code, request latency in ns, bytes out, bytes in,
-// response body, and lastly the error.
+// the error, response body, attack, sequence number, method, URL,
+// headers, title, scenario, numeric status, redirect hop, response
+// time in ns, and lastly the DNS, connect, TLS handshake, first byte
+// and transfer timings in ns.
 func NewCSVEncoder(w io.Writer) Encoder {
 	enc := csv.NewWriter(w)
 	return func(r *Result) error {
@@ -185,6 +219,16 @@ func NewCSVEncoder(w io.Writer) Encoder {
 			r.Method,
 			r.URL,
 			base64.StdEncoding.EncodeToString(headerBytes(r.Headers)),
+			r.Title,
+			r.Scenario,
+			strconv.Itoa(r.Status),
+			strconv.Itoa(r.Hop),
+			strconv.FormatInt(r.ResponseTime.Nanoseconds(), 10),
+			strconv.FormatInt(r.Timings.DNS.Nanoseconds(), 10),
+			strconv.FormatInt(r.Timings.Connect.Nanoseconds(), 10),
+			strconv.FormatInt(r.Timings.TLSHandshake.Nanoseconds(), 10),
+			strconv.FormatInt(r.Timings.FirstByte.Nanoseconds(), 10),
+			strconv.FormatInt(r.Timings.Transfer.Nanoseconds(), 10),
 		})
 		if err != nil {
 			return err
@@ -208,7 +252,7 @@ func headerBytes(h http.Header) []byte {
 // NewCSVDecoder returns a Decoder that decodes CSV encoded Results.
 func NewCSVDecoder(r io.Reader) Decoder {
 	dec := csv.NewReader(r)
-	dec.FieldsPerRecord = 12
+	dec.FieldsPerRecord = 22
 	dec.TrimLeadingSpace = true
 
 	return func(r *Result) error {
@@ -222,6 +266,7 @@ func NewCSVDecoder(r io.Reader) Decoder {
 			return err
 		}
 		r.Timestamp = time.Unix(0, ts)
+		r.Code = rec[1]
 
 		latency, err := strconv.ParseInt(rec[2], 10, 64)
 		if err != nil {
@@ -260,7 +305,26 @@ func NewCSVDecoder(r io.Reader) Decoder {
 			r.Headers = http.Header(hdr)
 		}
 
-		return err
+		r.Title = rec[12]
+		r.Scenario = rec[13]
+		if r.Status, err = strconv.Atoi(rec[14]); err != nil {
+			return err
+		}
+		if r.Hop, err = strconv.Atoi(rec[15]); err != nil {
+			return err
+		}
+
+		durations := []*time.Duration{&r.ResponseTime, &r.Timings.DNS, &r.Timings.Connect,
+			&r.Timings.TLSHandshake, &r.Timings.FirstByte, &r.Timings.Transfer}
+		for i, d := range durations {
+			ns, err := strconv.ParseInt(rec[16+i], 10, 64)
+			if err != nil {
+				return err
+			}
+			*d = time.Duration(ns)
+		}
+
+		return nil
 	}
 }
 
@@ -1,6 +1,8 @@
 package stats
 
 import (
+	"io"
+	"log"
 	"sync"
 	"time"
 
@@ -14,6 +16,11 @@ var mutex = &sync.Mutex{}
 type Metrics struct {
 	// Latencies holds computed request latency metrics.
 	Latencies LatencyMetrics `json:"latencies"`
+	// ResponseTimes holds coordinated-omission corrected latency metrics, measured
+	// from when a request was scheduled rather than from when it was sent.
+	ResponseTimes LatencyMetrics `json:"response_times"`
+	// Phases breaks http request latencies down by phase.
+	Phases PhaseMetrics `json:"phases"`
 	// Histogram, only if requested
 	Histogram *Histogram `json:"buckets,omitempty"`
 	// BytesIn holds computed incoming byte metrics.
@@ -25,7 +32,7 @@ type Metrics struct {
 	// Latest is the latest timestamp in a Result set.
 	Latest time.Time `json:"latest"`
 	// End is the latest timestamp in a Request.
-	reqLatest time.Time `json:"complete"`
+	reqLatest time.Time
 	// End is the latest timestamp in a Result set plus its latency.
 	End time.Time `json:"end"`
 	// Duration is the duration of the attack.
@@ -44,6 +51,15 @@ type Metrics struct {
 	StatusCodes map[string]int `json:"status_codes"`
 	// Errors is a set of unique errors returned by the targets during the attack.
 	Errors []string `json:"errors"`
+	// Checks holds the outcome of every response check, keyed by action title and check.
+	Checks map[string]*CheckMetrics `json:"checks,omitempty"`
+	// Groups breaks the metrics down by action title or request, see SetGroupBy.
+	Groups map[string]*GroupMetrics `json:"groups,omitempty"`
+	// Scenarios breaks the metrics down by the scenario of the users.
+	Scenarios map[string]*GroupMetrics `json:"scenarios,omitempty"`
+	// DroppedIterations is the number of iterations an arrival-rate executor could
+	// not start because no preallocated user was idle.
+	DroppedIterations uint64 `json:"dropped_iterations"`
 
 	errors  map[string]struct{}
 	success uint64
@@ -51,11 +67,58 @@ type Metrics struct {
 	Slowest [5]Result `json:"slowest"`
 }
 
+var recordMutex = &sync.Mutex{}
+var recorder Encoder
+
+// Record makes every Result added from now on also be encoded with enc, e.g. into a
+// results file for later reports. A nil enc stops recording.
+func Record(enc Encoder) {
+	recordMutex.Lock()
+	recorder = enc
+	recordMutex.Unlock()
+}
+
 func Add(id int, r *Result) {
 	mt := GetMetric(id)
 	if mt != nil {
 		mt.Add(r)
 	}
+	recordMutex.Lock()
+	if recorder != nil {
+		if err := recorder.Encode(r); err != nil {
+			log.Printf("Error: could not record result, recording stopped: %v\n", err)
+			recorder = nil
+		}
+	}
+	recordMutex.Unlock()
+}
+
+// Replay adds all Results decoded by dec to m as if they had been added during a run,
+// so a recorded run can be reported on again.
+func (m *Metrics) Replay(dec Decoder) error {
+	for {
+		var r Result
+		if err := dec.Decode(&r); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if r.Hop > 0 {
+			m.Add(&r)
+			continue
+		}
+		mutex.Lock()
+		m.Requests++
+		sent := r.Timestamp.Add(-r.Latency)
+		if sent.After(m.reqLatest) {
+			m.reqLatest = sent
+		}
+		if m.Earliest.IsZero() || sent.Before(m.Earliest) {
+			m.Earliest = sent
+		}
+		mutex.Unlock()
+		m.Add(&r)
+	}
 }
 
 // Add implements the Add method of the Report interface by adding the given
@@ -63,6 +126,11 @@ func Add(id int, r *Result) {
 func (m *Metrics) Add(r *Result) {
 	m.Init()
 	mutex.Lock()
+	if r.Hop > 0 {
+		m.group(r).add(r)
+		mutex.Unlock()
+		return
+	}
 	// m.Requests++
 	m.StatusCodes[r.Code]++
 	m.BytesOut.Total += r.BytesOut
@@ -70,6 +138,12 @@ func (m *Metrics) Add(r *Result) {
 	// m.Rate = float64(m.Requests)
 
 	m.Latencies.Add(r.Latency)
+	if r.ResponseTime > 0 {
+		m.ResponseTimes.Add(r.ResponseTime)
+	} else {
+		m.ResponseTimes.Add(r.Latency)
+	}
+	m.Phases.Add(r.Timings)
 
 	if m.Earliest.IsZero() || m.Earliest.After(r.Timestamp) {
 		m.Earliest = r.Timestamp
@@ -91,11 +165,10 @@ func (m *Metrics) Add(r *Result) {
 		m.success++
 	}
 
-	if r.Error != "" {
-		if _, ok := m.errors[r.Error]; !ok {
-			m.errors[r.Error] = struct{}{}
-			m.Errors = append(m.Errors, r.Error)
-		}
+	m.addError(r.Error)
+	m.group(r).add(r)
+	if r.Scenario != "" {
+		m.scenario(r).add(r)
 	}
 
 	m.AddToSlowest(*r)
@@ -106,6 +179,7 @@ func (m *Metrics) Add(r *Result) {
 // derived summary metrics which don't need to be run on every Add call.
 func (m *Metrics) Close() {
 	mutex.Lock()
+	defer mutex.Unlock()
 	m.init()
 
 	if m.Requests == 0 {
@@ -129,12 +203,51 @@ func (m *Metrics) Close() {
 	m.BytesIn.Mean = float64(m.BytesIn.Total) / float64(m.Requests)
 	m.BytesOut.Mean = float64(m.BytesOut.Total) / float64(m.Requests)
 	m.Success = float64(m.success) / float64(m.Requests)
-	m.Latencies.Mean = time.Duration(float64(m.Latencies.Total) / float64(m.Requests))
-	m.Latencies.P50 = m.Latencies.Quantile(0.50)
-	m.Latencies.P90 = m.Latencies.Quantile(0.90)
-	m.Latencies.P95 = m.Latencies.Quantile(0.95)
-	m.Latencies.P99 = m.Latencies.Quantile(0.99)
-	mutex.Unlock()
+	m.Latencies.close(m.Requests)
+	m.ResponseTimes.close(m.Requests)
+	m.Phases.close()
+	for _, g := range m.Groups {
+		g.close()
+	}
+	for _, g := range m.Scenarios {
+		g.close()
+	}
+}
+
+func (m *Metrics) addError(e string) {
+	if e != "" {
+		if _, ok := m.errors[e]; !ok {
+			m.errors[e] = struct{}{}
+			m.Errors = append(m.Errors, e)
+		}
+	}
+}
+
+// group returns the metrics of the group r belongs to.
+func (m *Metrics) group(r *Result) *GroupMetrics {
+	if m.Groups == nil {
+		m.Groups = map[string]*GroupMetrics{}
+	}
+	name := groupName(r)
+	g, ok := m.Groups[name]
+	if !ok {
+		g = newGroupMetrics()
+		m.Groups[name] = g
+	}
+	return g
+}
+
+// scenario returns the metrics of the scenario of r.
+func (m *Metrics) scenario(r *Result) *GroupMetrics {
+	if m.Scenarios == nil {
+		m.Scenarios = map[string]*GroupMetrics{}
+	}
+	g, ok := m.Scenarios[r.Scenario]
+	if !ok {
+		g = newGroupMetrics()
+		m.Scenarios[r.Scenario] = g
+	}
+	return g
 }
 
 func (m *Metrics) init() {
@@ -155,6 +268,12 @@ func (m *Metrics) Init() {
 	}
 }
 
+// CheckMetrics counts how often a response check passed and failed.
+type CheckMetrics struct {
+	Passes uint64 `json:"passes"`
+	Fails  uint64 `json:"fails"`
+}
+
 // LatencyMetrics holds computed request latency metrics.
 type LatencyMetrics struct {
 	// Total is the total latency sum of all requests in an attack.
@@ -189,6 +308,18 @@ func (l *LatencyMetrics) Add(latency time.Duration) {
 	l.estimator.Add(float64(latency))
 }
 
+// close computes the mean and quantiles out of n added latencies.
+func (l *LatencyMetrics) close(n uint64) {
+	if n == 0 {
+		return
+	}
+	l.Mean = time.Duration(float64(l.Total) / float64(n))
+	l.P50 = l.Quantile(0.50)
+	l.P90 = l.Quantile(0.90)
+	l.P95 = l.Quantile(0.95)
+	l.P99 = l.Quantile(0.99)
+}
+
 // Quantile returns the nth quantile from the latency summary.
 func (l LatencyMetrics) Quantile(nth float64) time.Duration {
 	l.init()
@@ -203,6 +334,50 @@ func (l *LatencyMetrics) init() {
 	}
 }
 
+// PhaseLatencies holds the latency metrics of one http request phase. Count is the
+// number of requests that went through the phase, e.g. only those that opened a
+// new connection for Connect.
+type PhaseLatencies struct {
+	LatencyMetrics
+	Count uint64 `json:"count"`
+}
+
+func (p *PhaseLatencies) add(d time.Duration) {
+	if d <= 0 {
+		return
+	}
+	p.Count++
+	p.LatencyMetrics.Add(d)
+}
+
+// PhaseMetrics holds computed latency metrics per http request phase.
+type PhaseMetrics struct {
+	DNS          PhaseLatencies `json:"dns"`
+	Connect      PhaseLatencies `json:"connect"`
+	TLSHandshake PhaseLatencies `json:"tls_handshake"`
+	FirstByte    PhaseLatencies `json:"first_byte"`
+	Transfer     PhaseLatencies `json:"transfer"`
+}
+
+// Add adds the phase timings of a request to the phase metrics.
+func (p *PhaseMetrics) Add(t Timings) {
+	p.DNS.add(t.DNS)
+	p.Connect.add(t.Connect)
+	p.TLSHandshake.add(t.TLSHandshake)
+	p.FirstByte.add(t.FirstByte)
+	p.Transfer.add(t.Transfer)
+}
+
+func (p *PhaseMetrics) close() {
+	for _, l := range p.list() {
+		l.close(l.Count)
+	}
+}
+
+func (p *PhaseMetrics) list() []*PhaseLatencies {
+	return []*PhaseLatencies{&p.DNS, &p.Connect, &p.TLSHandshake, &p.FirstByte, &p.Transfer}
+}
+
 // ByteMetrics holds computed byte flow metrics.
 type ByteMetrics struct {
 	// Total is the total number of flowing bytes in an attack.
@@ -283,6 +458,64 @@ func (m *Metrics) AddRequest(r *string) {
 	mutex.Unlock()
 }
 
+func AddDroppedIteration(id int) {
+	mt := GetMetric(id)
+	if mt != nil {
+		mt.AddDroppedIteration()
+	}
+}
+
+func (m *Metrics) AddDroppedIteration() {
+	mutex.Lock()
+	m.DroppedIterations++
+	mutex.Unlock()
+}
+
+func AddError(id int, r *Result) {
+	mt := GetMetric(id)
+	if mt != nil {
+		mt.AddError(r)
+	}
+}
+
+// AddError adds the error of r, e.g. a response that could not be processed, to the
+// error sets without counting r as another result.
+func (m *Metrics) AddError(r *Result) {
+	m.Init()
+	mutex.Lock()
+	m.addError(r.Error)
+	m.group(r).addError(r.Error)
+	if r.Scenario != "" {
+		m.scenario(r).addError(r.Error)
+	}
+	mutex.Unlock()
+}
+
+func AddCheck(id int, name string, passed bool) {
+	mt := GetMetric(id)
+	if mt != nil {
+		mt.AddCheck(name, passed)
+	}
+}
+
+func (m *Metrics) AddCheck(name string, passed bool) {
+	mutex.Lock()
+	if m.Checks == nil {
+		m.Checks = map[string]*CheckMetrics{}
+	}
+	c, ok := m.Checks[name]
+	if !ok {
+		c = &CheckMetrics{}
+		m.Checks[name] = c
+	}
+	if passed {
+		c.Passes++
+	} else {
+		c.Fails++
+	}
+	mutex.Unlock()
+}
+
 func (m *Metrics) AddToSlowest(res Result) {
 	var slot int
 	for i, s := range m.Slowest {
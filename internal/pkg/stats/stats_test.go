@@ -0,0 +1,86 @@
+package stats
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetrics_Scenarios(t *testing.T) {
+	m := &Metrics{}
+	m.Add(&Result{Title: "Search", Scenario: "searchers", Status: 200, Latency: 100 * time.Millisecond})
+	m.Add(&Result{Title: "Search", Scenario: "buyers", Status: 200, Latency: 200 * time.Millisecond})
+	m.Add(&Result{Title: "Checkout", Scenario: "buyers", Status: 500, Latency: 300 * time.Millisecond, Error: "boom"})
+	m.Add(&Result{Title: "Checkout", Scenario: "buyers", Hop: 1, Status: 302})
+	m.AddError(&Result{Title: "Checkout", Scenario: "buyers", Error: "bad body"})
+	for _, g := range m.Scenarios {
+		g.close()
+	}
+
+	assert.Len(t, m.Scenarios, 2)
+	assert.Equal(t, uint64(1), m.Scenarios["searchers"].Requests)
+	buyers := m.Scenarios["buyers"]
+	assert.Equal(t, uint64(2), buyers.Requests)
+	assert.Equal(t, 0.5, buyers.Success)
+	assert.Equal(t, []string{"boom", "bad body"}, buyers.Errors)
+
+	var text bytes.Buffer
+	assert.NoError(t, NewTextReporter(m).Report(&text))
+	assert.Contains(t, text.String(), "By Scenario")
+	assert.Regexp(t, `\n  buyers\s+2, 50.00%`, text.String())
+
+	var report struct {
+		Scenarios map[string]GroupMetrics `json:"scenarios"`
+	}
+	var js bytes.Buffer
+	assert.NoError(t, NewJSONReporter(m).Report(&js))
+	assert.NoError(t, json.Unmarshal(js.Bytes(), &report))
+	assert.Equal(t, uint64(1), report.Scenarios["searchers"].Requests)
+}
+
+func TestResult_ScenarioEncoding(t *testing.T) {
+	r := Result{Title: "Search", Scenario: "searchers", Status: 200, Timestamp: time.Unix(0, 42)}
+	var js, gob bytes.Buffer
+	assert.NoError(t, NewJSONEncoder(&js).Encode(&r))
+	assert.NoError(t, NewEncoder(&gob).Encode(&r))
+
+	var got Result
+	assert.NoError(t, NewJSONDecoder(&js).Decode(&got))
+	assert.True(t, r.Equal(got))
+	got = Result{}
+	assert.NoError(t, NewDecoder(&gob).Decode(&got))
+	assert.Equal(t, "searchers", got.Scenario)
+}
+
+func TestResult_CSVRoundTrip(t *testing.T) {
+	r := Result{
+		Attack: "run", Title: "Search", Scenario: "searchers", Seq: 3, Code: "200", Status: 200, Hop: 1,
+		Timestamp: time.Unix(0, 42), Latency: 90 * time.Millisecond, ResponseTime: 120 * time.Millisecond,
+		Timings:  Timings{DNS: 1, Connect: 2, TLSHandshake: 3, FirstByte: 4, Transfer: 5},
+		BytesOut: 10, BytesIn: 20, Error: "boom, twice", Body: []byte("body"),
+		Method: "GET", URL: "http://localhost/search?q=a,b", Headers: http.Header{"Content-Type": []string{"text/plain"}},
+	}
+	var buf bytes.Buffer
+	assert.NoError(t, NewCSVEncoder(&buf).Encode(&r))
+
+	var got Result
+	assert.NoError(t, NewCSVDecoder(&buf).Decode(&got))
+	assert.True(t, r.Equal(got), "%+v", got)
+
+	buf.Reset()
+	r.Hop = 0
+	assert.NoError(t, NewCSVEncoder(&buf).Encode(&r))
+	dec := DecoderFor(&buf)
+	assert.NotNil(t, dec)
+	m := &Metrics{}
+	assert.NoError(t, dec.Decode(&got))
+	m.Add(&got)
+	m.Groups["Search"].close()
+	assert.Equal(t, uint64(1), m.Scenarios["searchers"].Requests)
+	assert.Equal(t, uint64(1), m.Groups["Search"].Requests)
+	assert.Equal(t, 1.0, m.Groups["Search"].Success)
+}
@@ -0,0 +1,239 @@
+package stats
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// Threshold is a pass/fail rule on a metric of a test run, e.g. "p95 < 300ms" or
+// "success > 99%". Supported metrics are:
+//
+//	min, mean, p50, p90, p95, p99, max   request latency (durations)
+//	rt_min, rt_mean, ..., rt_max         corrected response time (durations)
+//	success, checks                      ratio of successful requests / passed checks (percentages)
+//	requests, rate, throughput, dropped  counts and per-second rates (numbers)
+//
+// A Threshold with a Title only applies to the results grouped under that title
+// (see SetGroupBy); for those the rate, throughput and dropped metrics are not available.
+type Threshold struct {
+	Title  string
+	Rule   string
+	Metric string
+	Op     string
+	Value  float64
+}
+
+type metricKind int
+
+const (
+	durationMetric metricKind = iota
+	ratioMetric
+	numberMetric
+)
+
+var thresholdMetrics = map[string]metricKind{
+	"min": durationMetric, "mean": durationMetric, "p50": durationMetric, "p90": durationMetric,
+	"p95": durationMetric, "p99": durationMetric, "max": durationMetric,
+	"rt_min": durationMetric, "rt_mean": durationMetric, "rt_p50": durationMetric, "rt_p90": durationMetric,
+	"rt_p95": durationMetric, "rt_p99": durationMetric, "rt_max": durationMetric,
+	"success": ratioMetric, "checks": ratioMetric,
+	"requests": numberMetric, "rate": numberMetric, "throughput": numberMetric, "dropped": numberMetric,
+}
+
+var thresholdRe = regexp.MustCompile(`^\s*([a-z0-9_]+)\s*(<=|>=|==|!=|<|>)\s*(\S+)\s*$`)
+
+// ParseThreshold parses rule, restricted to the actions titled title if not empty.
+func ParseThreshold(title, rule string) (Threshold, error) {
+	t := Threshold{Title: title, Rule: strings.TrimSpace(rule)}
+	match := thresholdRe.FindStringSubmatch(rule)
+	if match == nil {
+		return t, fmt.Errorf("invalid threshold '%s', expected <metric> <op> <value>", rule)
+	}
+	t.Metric, t.Op = match[1], match[2]
+	kind, ok := thresholdMetrics[t.Metric]
+	if !ok {
+		return t, fmt.Errorf("unknown metric '%s' in threshold '%s'", t.Metric, rule)
+	}
+	if title != "" && (t.Metric == "rate" || t.Metric == "throughput" || t.Metric == "dropped") {
+		return t, fmt.Errorf("threshold '%s' for '%s': the %s metric can't be used per title", rule, title, t.Metric)
+	}
+	var err error
+	switch kind {
+	case durationMetric:
+		var d time.Duration
+		d, err = time.ParseDuration(match[3])
+		t.Value = float64(d)
+	case ratioMetric:
+		if strings.HasSuffix(match[3], "%") {
+			t.Value, err = strconv.ParseFloat(strings.TrimSuffix(match[3], "%"), 64)
+			t.Value /= 100
+		} else {
+			t.Value, err = strconv.ParseFloat(match[3], 64)
+		}
+	default:
+		t.Value, err = strconv.ParseFloat(match[3], 64)
+	}
+	if err != nil {
+		return t, fmt.Errorf("invalid value in threshold '%s': %v", rule, err)
+	}
+	return t, nil
+}
+
+// Evaluate returns the actual value of the threshold's metric in m and whether the
+// threshold holds. A threshold with a title no results were grouped under does not
+// hold, so a misspelled title can't pass without data. Callers must not hold the stats
+// lock and m should be closed.
+func (t Threshold) Evaluate(m *Metrics) (float64, bool) {
+	if t.missing(m) {
+		return 0, false
+	}
+	actual := t.actual(m)
+	switch t.Op {
+	case "<":
+		return actual, actual < t.Value
+	case "<=":
+		return actual, actual <= t.Value
+	case ">":
+		return actual, actual > t.Value
+	case ">=":
+		return actual, actual >= t.Value
+	case "==":
+		return actual, actual == t.Value
+	default:
+		return actual, actual != t.Value
+	}
+}
+
+func (t Threshold) actual(m *Metrics) float64 {
+	mutex.Lock()
+	defer mutex.Unlock()
+	l, rt, success, requests := m.Latencies, m.ResponseTimes, m.Success, m.Requests
+	if t.Title != "" {
+		g, ok := m.Groups[t.Title]
+		if !ok {
+			g = newGroupMetrics()
+		}
+		l, rt, success, requests = g.Latencies, g.ResponseTimes, g.Success, g.Requests
+	}
+	if strings.HasPrefix(t.Metric, "rt_") {
+		l = rt
+	}
+	switch strings.TrimPrefix(t.Metric, "rt_") {
+	case "min":
+		return float64(l.Min)
+	case "mean":
+		return float64(l.Mean)
+	case "p50":
+		return float64(l.P50)
+	case "p90":
+		return float64(l.P90)
+	case "p95":
+		return float64(l.P95)
+	case "p99":
+		return float64(l.P99)
+	case "max":
+		return float64(l.Max)
+	case "success":
+		return success
+	case "checks":
+		var passes, total uint64
+		for name, c := range m.Checks {
+			if t.Title == "" || strings.HasPrefix(name, t.Title+": ") {
+				passes += c.Passes
+				total += c.Passes + c.Fails
+			}
+		}
+		if total == 0 {
+			return 1
+		}
+		return float64(passes) / float64(total)
+	case "requests":
+		return float64(requests)
+	case "rate":
+		return m.Rate
+	case "throughput":
+		return m.Throughput
+	case "dropped":
+		return float64(m.DroppedIterations)
+	}
+	return 0
+}
+
+// missing reports whether t has a title m has no results for, or no checks for if
+// the metric of t is checks.
+func (t Threshold) missing(m *Metrics) bool {
+	if t.Title == "" {
+		return false
+	}
+	mutex.Lock()
+	defer mutex.Unlock()
+	if t.Metric == "checks" {
+		for name := range m.Checks {
+			if strings.HasPrefix(name, t.Title+": ") {
+				return false
+			}
+		}
+		return true
+	}
+	_, ok := m.Groups[t.Title]
+	return !ok
+}
+
+func (t Threshold) format(v float64) string {
+	switch thresholdMetrics[t.Metric] {
+	case durationMetric:
+		return round(time.Duration(v)).String()
+	case ratioMetric:
+		return fmt.Sprintf("%.2f%%", v*100)
+	default:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+}
+
+func (t Threshold) String() string {
+	if t.Title != "" {
+		return t.Title + ": " + t.Rule
+	}
+	return t.Rule
+}
+
+// CheckThresholds evaluates all thresholds against m and reports whether all of them hold.
+func CheckThresholds(m *Metrics, thresholds []Threshold) bool {
+	for _, t := range thresholds {
+		if _, ok := t.Evaluate(m); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// NewThresholdReporter returns a Reporter that writes out whether each threshold
+// holds for m as an aligned pass/fail table.
+func NewThresholdReporter(m *Metrics, thresholds []Threshold) Reporter {
+	return func(w io.Writer) (err error) {
+		tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', tabwriter.StripEscape)
+		if _, err = fmt.Fprintf(tw, "Thresholds\t[actual]\t[result]\n"); err != nil {
+			return err
+		}
+		for _, t := range thresholds {
+			actual, ok := t.Evaluate(m)
+			result := "PASS"
+			if !ok {
+				result = "FAIL"
+			}
+			value := t.format(actual)
+			if t.missing(m) {
+				value = "no such group"
+			}
+			if _, err = fmt.Fprintf(tw, "  %s\t%s\t%s\n", t, value, result); err != nil {
+				return err
+			}
+		}
+		return tw.Flush()
+	}
+}
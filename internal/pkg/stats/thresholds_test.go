@@ -0,0 +1,83 @@
+package stats
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseThreshold(t *testing.T) {
+	th, err := ParseThreshold("", "p95 < 300ms")
+	assert.NoError(t, err)
+	assert.Equal(t, "p95", th.Metric)
+	assert.Equal(t, "<", th.Op)
+	assert.Equal(t, float64(300*time.Millisecond), th.Value)
+
+	th, err = ParseThreshold("", "success >= 99.5%")
+	assert.NoError(t, err)
+	assert.InDelta(t, 0.995, th.Value, 1e-9)
+
+	_, err = ParseThreshold("", "p42 < 1s")
+	assert.Error(t, err)
+	_, err = ParseThreshold("", "p95 < fast")
+	assert.Error(t, err)
+	_, err = ParseThreshold("Get course", "p95 < 1s")
+	assert.NoError(t, err)
+	_, err = ParseThreshold("Get course", "rate > 10")
+	assert.Error(t, err)
+}
+
+func TestThreshold_Evaluate(t *testing.T) {
+	m := &Metrics{Success: 0.98}
+	m.Latencies.P95 = 250 * time.Millisecond
+	m.AddCheck("Get course: status in [200]", true)
+	m.AddCheck("Get course: status in [200]", false)
+	m.AddCheck("Login: status in [200]", true)
+
+	for rule, want := range map[string]bool{
+		"p95 < 300ms":   true,
+		"p95 < 200ms":   false,
+		"success > 99%": false,
+		"checks > 60%":  true,
+	} {
+		th, err := ParseThreshold("", rule)
+		assert.NoError(t, err)
+		_, ok := th.Evaluate(m)
+		assert.Equal(t, want, ok, rule)
+	}
+
+	th, _ := ParseThreshold("Get course", "checks > 60%")
+	actual, ok := th.Evaluate(m)
+	assert.False(t, ok)
+	assert.Equal(t, 0.5, actual)
+}
+
+func TestThreshold_EvaluateGroup(t *testing.T) {
+	m := &Metrics{}
+	m.Add(&Result{Title: "Get course", Status: 200, Latency: 100 * time.Millisecond})
+	m.Add(&Result{Title: "Post course", Status: 500, Latency: 900 * time.Millisecond, Error: "boom"})
+	for _, g := range m.Groups {
+		g.close()
+	}
+
+	th, _ := ParseThreshold("Get course", "max < 500ms")
+	_, ok := th.Evaluate(m)
+	assert.True(t, ok)
+	th, _ = ParseThreshold("Post course", "max < 500ms")
+	_, ok = th.Evaluate(m)
+	assert.False(t, ok)
+	th, _ = ParseThreshold("Post course", "success > 0%")
+	_, ok = th.Evaluate(m)
+	assert.False(t, ok)
+	assert.Equal(t, []string{"boom"}, m.Groups["Post course"].Errors)
+
+	th, _ = ParseThreshold("Get courses", "p95 < 300ms")
+	_, ok = th.Evaluate(m)
+	assert.False(t, ok)
+
+	var out strings.Builder
+	assert.NoError(t, NewThresholdReporter(m, []Threshold{th})(&out))
+	assert.Contains(t, out.String(), "Get courses: p95 < 300ms  no such group  FAIL")
+}
@@ -0,0 +1,124 @@
+package testdef
+
+import (
+	"math"
+	"sort"
+)
+
+// Scenario is one of several groups of users of a test that run their own actions at
+// the same time, e.g. browsers, searchers and buyers. A scenario with users of its own
+// runs that many; the others share the users of the test by weight, 1 by default.
+// Iterations, duration and rampup it leaves out are those of the test, and so are the
+// executor and stages unless it sets either. The arrival rate and iterations of an
+// inherited arrival-rate executor and the users of inherited stages are shared by
+// weight as well. The request rate of the test applies to all scenarios together.
+type Scenario struct {
+	Name       string                   `yaml:"name"`
+	Weight     int                      `yaml:"weight"`
+	Users      int                      `yaml:"users"`
+	Iterations int                      `yaml:"iterations"`
+	Duration   Duration                 `yaml:"duration"`
+	Rampup     int                      `yaml:"rampup"`
+	Stages     []Stage                  `yaml:"stages"`
+	Executor   Executor                 `yaml:"executor"`
+	Actions    []map[string]interface{} `yaml:"actions"`
+}
+
+func (s Scenario) weight() int {
+	if s.Weight == 0 {
+		return 1
+	}
+	return s.Weight
+}
+
+// ScenarioDefs returns the test definition of every scenario of t: a copy of t with
+// the actions and load settings of the scenario. A test without scenarios is its own
+// only one.
+func (t *TestDef) ScenarioDefs() []*TestDef {
+	if len(t.Scenarios) == 0 {
+		return []*TestDef{t}
+	}
+	users, shares := t.weightedUsers()
+	defs := make([]*TestDef, len(t.Scenarios))
+	for i, s := range t.Scenarios {
+		d := *t
+		d.Scenario = s.Name
+		d.Scenarios = nil
+		d.Actions = s.Actions
+		d.Users = users[i]
+		if s.Duration != 0 {
+			d.Duration = s.Duration
+		}
+		if s.Rampup != 0 {
+			d.Rampup = s.Rampup
+		}
+		inherited := s.Executor.Type == "" && len(s.Stages) == 0
+		if inherited {
+			d.Executor.Rate = t.Executor.Rate * shares[i]
+			d.Stages = scaleStages(t.Stages, shares[i])
+		} else {
+			d.Executor, d.Stages = s.Executor, s.Stages
+		}
+		d.Iterations = s.Iterations
+		if d.Iterations == 0 {
+			d.Iterations = t.Iterations
+			if inherited && d.Executor.IsArrivalRate() {
+				d.Iterations = int(math.Ceil(float64(t.Iterations) * shares[i]))
+			}
+		}
+		defs[i] = &d
+	}
+	return defs
+}
+
+// weightedUsers returns the users of every scenario: its own, or its share by weight
+// of the users of t. The shares of the weighted scenarios are also returned, those
+// with users of their own have a share of 1. Users left over after rounding down go to
+// the scenarios that lost most by it.
+func (t *TestDef) weightedUsers() ([]int, []float64) {
+	users := make([]int, len(t.Scenarios))
+	shares := make([]float64, len(t.Scenarios))
+	var weighted []int
+	total := 0
+	for i, s := range t.Scenarios {
+		if s.Users > 0 {
+			users[i], shares[i] = s.Users, 1
+			continue
+		}
+		weighted = append(weighted, i)
+		total += s.weight()
+	}
+	left := t.Users
+	remainders := make(map[int]float64, len(weighted))
+	for _, i := range weighted {
+		shares[i] = float64(t.Scenarios[i].weight()) / float64(total)
+		exact := shares[i] * float64(t.Users)
+		users[i] = int(exact)
+		remainders[i] = exact - float64(users[i])
+		left -= users[i]
+	}
+	sort.SliceStable(weighted, func(a, b int) bool { return remainders[weighted[a]] > remainders[weighted[b]] })
+	for _, i := range weighted {
+		if left <= 0 {
+			break
+		}
+		users[i]++
+		left--
+	}
+	return users, shares
+}
+
+func scaleStages(stages []Stage, share float64) []Stage {
+	if len(stages) == 0 || share == 1 {
+		return stages
+	}
+	scaled := make([]Stage, len(stages))
+	for i, s := range stages {
+		scaled[i] = s
+		if s.Users != nil {
+			users := int(math.Round(float64(*s.Users) * share))
+			scaled[i].Users = &users
+		}
+	}
+	return scaled
+}
@@ -0,0 +1,106 @@
+package testdef
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v2"
+)
+
+const scenariosSpec = `
+users: 101
+duration: 1m
+rampup: 10
+scenarios:
+  - name: browsers
+    weight: 70
+    actions:
+      - sleep:
+          duration: 1
+  - name: searchers
+    weight: 25
+    iterations: 3
+    actions:
+      - sleep:
+          duration: 1
+  - name: buyers
+    weight: 5
+    executor:
+      type: constant-arrival-rate
+      rate: 2
+    actions:
+      - sleep:
+          duration: 1
+  - name: admins
+    users: 2
+    duration: 30s
+    actions:
+      - sleep:
+          duration: 1
+`
+
+func TestScenarioDefs_SplitUsersByWeight(t *testing.T) {
+	var td TestDef
+	assert.NoError(t, yaml.Unmarshal([]byte(scenariosSpec), &td))
+	assert.True(t, ValidateTestDefinition(&td))
+
+	defs := td.ScenarioDefs()
+	assert.Len(t, defs, 4)
+	users := map[string]int{}
+	for _, d := range defs {
+		users[d.Scenario] = d.Users
+		assert.Len(t, d.Actions, 1, d.Scenario)
+		assert.Nil(t, d.Scenarios)
+	}
+	assert.Equal(t, map[string]int{"browsers": 71, "searchers": 25, "buyers": 5, "admins": 2}, users)
+	assert.Equal(t, 103, td.PeakUsers())
+
+	assert.Equal(t, 0, defs[0].Iterations)
+	assert.Equal(t, 3, defs[1].Iterations)
+	assert.Equal(t, 10, defs[1].Rampup)
+	assert.True(t, defs[2].Executor.IsArrivalRate())
+	assert.Equal(t, Duration(30*time.Second), defs[3].Duration)
+	assert.Equal(t, Duration(time.Minute), defs[0].Duration)
+}
+
+func TestScenarioDefs_ShareInheritedLoad(t *testing.T) {
+	ten, hundred := 10, 100
+	td := TestDef{
+		Users:      10,
+		Iterations: 100,
+		Duration:   Duration(time.Minute),
+		Executor:   Executor{Type: CONSTANT_ARRIVAL_RATE, Rate: 40},
+		Scenarios:  []Scenario{{Name: "a", Weight: 3}, {Name: "b"}},
+	}
+	defs := td.ScenarioDefs()
+	assert.Equal(t, 30.0, defs[0].Executor.Rate)
+	assert.Equal(t, 10.0, defs[1].Executor.Rate)
+	assert.Equal(t, 75, defs[0].Iterations)
+	assert.Equal(t, 25, defs[1].Iterations)
+
+	td = TestDef{Stages: []Stage{{Duration: Duration(time.Minute), Users: &hundred, Rate: &ten}},
+		Scenarios: []Scenario{{Name: "a", Weight: 3}, {Name: "b"}}}
+	defs = td.ScenarioDefs()
+	assert.Equal(t, 75, *defs[0].Stages[0].Users)
+	assert.Equal(t, 25, *defs[1].Stages[0].Users)
+	assert.Equal(t, 100, *td.Stages[0].Users)
+	assert.Equal(t, 100, td.PeakUsers())
+}
+
+func TestValidateTestDefinition_Scenarios(t *testing.T) {
+	action := []map[string]interface{}{{"sleep": map[interface{}]interface{}{"duration": 1}}}
+	rate := 5
+	for name, td := range map[string]TestDef{
+		"no name":        {Users: 2, Iterations: 1, Scenarios: []Scenario{{Actions: action}}},
+		"duplicate name": {Users: 2, Iterations: 1, Scenarios: []Scenario{{Name: "a", Actions: action}, {Name: "a", Actions: action}}},
+		"no actions":     {Users: 2, Iterations: 1, Scenarios: []Scenario{{Name: "a"}}},
+		"actions too":    {Users: 2, Iterations: 1, Actions: action, Scenarios: []Scenario{{Name: "a", Actions: action}}},
+		"no users":       {Users: 1, Iterations: 1, Scenarios: []Scenario{{Name: "a", Actions: action}, {Name: "b", Actions: action}}},
+		"stage rate":     {Users: 2, Iterations: 1, Scenarios: []Scenario{{Name: "a", Actions: action, Stages: []Stage{{Duration: Duration(time.Minute), Rate: &rate}}}}},
+		"no iterations":  {Users: 2, Scenarios: []Scenario{{Name: "a", Actions: action}}},
+	} {
+		assert.False(t, ValidateTestDefinition(&td), name)
+	}
+	assert.True(t, ValidateTestDefinition(&TestDef{Iterations: 1, Scenarios: []Scenario{{Name: "a", Users: 1, Actions: action}}}))
+}
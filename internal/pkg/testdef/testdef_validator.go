@@ -25,23 +25,193 @@ package testdef
 
 import (
 	"log"
+	"net/url"
 )
 
 // TODO refactor this so it runs before parsing the actions
 
 func ValidateTestDefinition(t *TestDef) bool {
 	var valid = true
-	if t.Iterations == 0 {
-		log.Println("Iterations not set, must be > 0")
+	if len(t.Scenarios) > 0 {
+		valid = validateScenarios(t)
+	} else {
+		valid = validateLoad(t)
+	}
+	if t.Rate < 0 {
+		log.Println("Rate must be > -1")
+		valid = false
+	}
+	if t.Http.ConnectionPool != "" && t.Http.ConnectionPool != SHARED_POOL && t.Http.ConnectionPool != PER_USER_POOL {
+		log.Printf("Unknown http connectionPool '%s', must be %s or %s\n", t.Http.ConnectionPool, SHARED_POOL, PER_USER_POOL)
+		valid = false
+	}
+	if t.GroupBy != "" && t.GroupBy != GROUP_BY_TITLE && t.GroupBy != GROUP_BY_REQUEST {
+		log.Printf("Unknown groupBy '%s', must be %s or %s\n", t.GroupBy, GROUP_BY_TITLE, GROUP_BY_REQUEST)
+		valid = false
+	}
+	if t.Workers.Min < 0 || t.Workers.Max < 0 {
+		log.Println("Workers must be > -1")
+		valid = false
+	} else if t.Workers.Max > 0 && t.Workers.Max < t.Workers.Min {
+		log.Println("Workers max must be >= min")
+		valid = false
+	}
+	if t.Http.MaxIdleConnsPerHost < 0 {
+		log.Println("Http maxIdleConnsPerHost must be > -1")
+		valid = false
+	}
+	for i, c := range t.Http.Cookies.Seed {
+		if c.Name == "" {
+			log.Printf("Http cookie seed %d: must define a name\n", i+1)
+			valid = false
+		}
+		if u, err := url.Parse(c.Url); err != nil || u.Host == "" {
+			log.Printf("Http cookie seed %d: must define an absolute url\n", i+1)
+			valid = false
+		}
+	}
+	if t.Http.FollowRedirects.Max < 0 {
+		log.Println("Http followRedirects must be > -1")
+		valid = false
+	}
+	if to := t.Http.Timeouts; to.Timeout < 0 || to.ConnectTimeout < 0 || to.TLSTimeout < 0 || to.ResponseHeaderTimeout < 0 {
+		log.Println("Http timeouts must be > -1")
+		valid = false
+	}
+	return valid
+}
+
+func validateScenarios(t *TestDef) bool {
+	var valid = true
+	if len(t.Actions) > 0 {
+		log.Println("A test can not define both actions and scenarios, move the actions into a scenario")
+		valid = false
+	}
+	names := map[string]bool{}
+	for i, s := range t.Scenarios {
+		if s.Name == "" {
+			log.Printf("Scenario %d: must define a name\n", i+1)
+			valid = false
+		} else if names[s.Name] {
+			log.Printf("Scenario %d: name '%s' is used by another scenario\n", i+1, s.Name)
+			valid = false
+		}
+		names[s.Name] = true
+		if s.Weight < 0 {
+			log.Printf("Scenario %d: weight must be > -1\n", i+1)
+			valid = false
+		}
+		if s.Users < 0 {
+			log.Printf("Scenario %d: users must be > -1\n", i+1)
+			valid = false
+		}
+		if len(s.Actions) == 0 {
+			log.Printf("Scenario %d: must define actions\n", i+1)
+			valid = false
+		}
+		for _, stage := range s.Stages {
+			if stage.Rate != nil {
+				log.Printf("Scenario %d: stages can not set a rate, the request rate is shared by all scenarios\n", i+1)
+				valid = false
+				break
+			}
+		}
+	}
+	for _, d := range t.ScenarioDefs() {
+		if !validateLoad(d) {
+			log.Printf("Scenario '%s' has invalid load settings, see above\n", d.Scenario)
+			valid = false
+		}
+	}
+	return valid
+}
+
+// validateLoad checks the settings of t that decide how many users run how often.
+func validateLoad(t *TestDef) bool {
+	var valid = true
+	if len(t.Stages) > 0 {
+		valid = validateStages(t.Stages)
+		if t.Users <= 0 && !stagesSetUsers(t.Stages) {
+			log.Println("Stages only set rates, so users must be > 0")
+			valid = false
+		}
+	} else {
+		if t.Iterations == 0 && t.Duration == 0 {
+			log.Println("Neither iterations nor duration set, at least one must be > 0")
+			valid = false
+		}
+		if t.Users <= 0 {
+			log.Println("Users must be > 0")
+			valid = false
+		}
+	}
+	if t.Iterations < 0 {
+		log.Println("Iterations must be > -1")
+		valid = false
+	}
+	if t.Duration < 0 {
+		log.Println("Duration must be > -1")
 		valid = false
 	}
 	if t.Rampup < 0 {
 		log.Println("Rampup not defined. must be > -1")
 		valid = false
 	}
-	if t.Users == 0 {
-		log.Println("Users must be > 0")
+	if t.Executor.Type != "" && !validateExecutor(t) {
 		valid = false
 	}
 	return valid
 }
+
+func validateExecutor(t *TestDef) bool {
+	var valid = true
+	if !t.Executor.IsArrivalRate() {
+		log.Printf("Unknown executor type '%s', must be %s or %s\n", t.Executor.Type, CONSTANT_ARRIVAL_RATE, POISSON_ARRIVAL_RATE)
+		return false
+	}
+	if t.Executor.Rate <= 0 {
+		log.Println("Executor rate must be > 0")
+		valid = false
+	}
+	if t.Duration <= 0 {
+		log.Println("Arrival-rate executors need a duration")
+		valid = false
+	}
+	if len(t.Stages) > 0 {
+		log.Println("Arrival-rate executors can not be combined with stages")
+		valid = false
+	}
+	return valid
+}
+
+func validateStages(stages []Stage) bool {
+	var valid = true
+	for i, s := range stages {
+		if s.Duration <= 0 {
+			log.Printf("Stage %d: duration must be > 0\n", i+1)
+			valid = false
+		}
+		if s.Users == nil && s.Rate == nil {
+			log.Printf("Stage %d: must define a target users and/or rate\n", i+1)
+			valid = false
+		}
+		if s.Users != nil && *s.Users < 0 {
+			log.Printf("Stage %d: users must be > -1\n", i+1)
+			valid = false
+		}
+		if s.Rate != nil && *s.Rate < 0 {
+			log.Printf("Stage %d: rate must be > -1\n", i+1)
+			valid = false
+		}
+	}
+	return valid
+}
+
+func stagesSetUsers(stages []Stage) bool {
+	for _, s := range stages {
+		if s.Users != nil && *s.Users > 0 {
+			return true
+		}
+	}
+	return false
+}
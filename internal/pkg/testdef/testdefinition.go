@@ -23,17 +23,323 @@ SOFTWARE.
 */
 package testdef
 
+import (
+	"fmt"
+	"time"
+)
+
 const FIRST = "first"
 const LAST = "last"
 const RANDOM = "random"
+const ALL = "all"
+
+const CONSTANT_ARRIVAL_RATE = "constant-arrival-rate"
+const POISSON_ARRIVAL_RATE = "poisson-arrival-rate"
+
+const SHARED_POOL = "shared"
+const PER_USER_POOL = "user"
+
+const GROUP_BY_TITLE = "title"
+const GROUP_BY_REQUEST = "request"
 
 type TestDef struct {
 	Iterations int                      `yaml:"iterations"`
+	Duration   Duration                 `yaml:"duration"`
 	Users      int                      `yaml:"users"`
 	Rampup     int                      `yaml:"rampup"`
 	Rate       int                      `yaml:"rate"`
+	Stages     []Stage                  `yaml:"stages"`
+	Executor   Executor                 `yaml:"executor"`
+	Thresholds []Threshold              `yaml:"thresholds"`
+	GroupBy    string                   `yaml:"groupBy"`
+	Workers    Workers                  `yaml:"workers"`
+	Http       HttpConfig               `yaml:"http"`
+	TLS        TLSConfig                `yaml:"tls"`
 	Feeder     Feeder                   `yaml:"feeder"`
 	Actions    []map[string]interface{} `yaml:"actions"`
+	Scenarios  []Scenario               `yaml:"scenarios"`
+	// Scenario is the name of the scenario of a definition made by ScenarioDefs.
+	Scenario string `yaml:"-"`
+}
+
+// Stage is one step of a multi-stage load profile. Over Duration the number of users
+// and the request rate move linearly from their values at the end of the previous
+// stage, or the users and rate of the test for the first one, to Users and Rate.
+// Leaving either out keeps the previous value; a rate of 0 means unlimited, and a
+// stage ramping from or to it keeps the limited rate throughout.
+type Stage struct {
+	Duration Duration `yaml:"duration"`
+	Users    *int     `yaml:"users"`
+	Rate     *int     `yaml:"rate"`
+}
+
+// Executor selects how iterations are started. By default every user runs its
+// iterations back to back, so a slow target slows down the offered load (a closed
+// model). The arrival-rate executors instead start Rate iterations per second, evenly
+// spaced or as a Poisson process, on idle users from a pool of `users` preallocated
+// users, independent of how fast earlier iterations complete (an open model).
+type Executor struct {
+	Type string  `yaml:"type"`
+	Rate float64 `yaml:"rate"`
+}
+
+// IsArrivalRate reports whether the executor is one of the open-model executors.
+func (e Executor) IsArrivalRate() bool {
+	return e.Type == CONSTANT_ARRIVAL_RATE || e.Type == POISSON_ARRIVAL_RATE
+}
+
+// TotalDuration returns how long the test runs for: the sum of all stages when
+// stages are defined, otherwise the duration setting (0 if unbounded).
+func (t *TestDef) TotalDuration() time.Duration {
+	if len(t.Stages) == 0 {
+		return time.Duration(t.Duration)
+	}
+	var total time.Duration
+	for _, s := range t.Stages {
+		total += time.Duration(s.Duration)
+	}
+	return total
+}
+
+// Deadline returns the wall-clock time at which a run started at start must stop
+// scheduling new iterations, or the zero time if the test has no duration.
+func (t *TestDef) Deadline(start time.Time) time.Time {
+	total := t.TotalDuration()
+	if total <= 0 {
+		return time.Time{}
+	}
+	return start.Add(total)
+}
+
+// Duration is a time.Duration that can be given in YAML either as an integer
+// number of seconds or as a Go duration string such as "15m" or "1h30m".
+type Duration time.Duration
+
+func (d *Duration) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var val interface{}
+	if err := unmarshal(&val); err != nil {
+		return err
+	}
+	switch v := val.(type) {
+	case int:
+		*d = Duration(time.Second * time.Duration(v))
+	case string:
+		dur, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("invalid duration '%v': %v", v, err)
+		}
+		*d = Duration(dur)
+	default:
+		return fmt.Errorf("unsupported duration value type. Supported is int or string (golang time.Duration), was %T", v)
+	}
+	return nil
+}
+
+// Workers sizes the pool of workers executing the actions. A number sets a fixed size;
+// with min and max the pool grows while tasks queue up and shrinks again when workers
+// idle. Left out, the pool scales from 20 up to the peak number of users.
+type Workers struct {
+	Min int `yaml:"min"`
+	Max int `yaml:"max"`
+}
+
+func (w *Workers) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var n int
+	if err := unmarshal(&n); err == nil {
+		w.Min, w.Max = n, n
+		return nil
+	}
+	type plain Workers
+	return unmarshal((*plain)(w))
+}
+
+// Default lower bound of the worker pool.
+const DEFAULT_MIN_WORKERS = 20
+
+// PoolSize returns the bounds of the worker pool.
+func (t *TestDef) PoolSize() (min, max int) {
+	min, max = t.Workers.Min, t.Workers.Max
+	if min == 0 && max == 0 {
+		min, max = DEFAULT_MIN_WORKERS, t.PeakUsers()
+	}
+	if max < min {
+		max = min
+	}
+	return min, max
+}
+
+// PeakUsers returns the highest number of users the test runs at once.
+func (t *TestDef) PeakUsers() int {
+	if len(t.Scenarios) > 0 {
+		peak := 0
+		for _, d := range t.ScenarioDefs() {
+			peak += d.PeakUsers()
+		}
+		return peak
+	}
+	peak := t.Users
+	for _, s := range t.Stages {
+		if s.Users != nil && *s.Users > peak {
+			peak = *s.Users
+		}
+	}
+	return peak
+}
+
+// HttpConfig holds the connection settings shared by all http actions.
+type HttpConfig struct {
+	// ConnectionPool is "shared" (the default) for one keep-alive connection pool used
+	// by all users, or "user" to give every user its own pool like separate browsers.
+	ConnectionPool      string    `yaml:"connectionPool"`
+	MaxIdleConnsPerHost int       `yaml:"maxIdleConnsPerHost"`
+	DisableKeepAlives   bool      `yaml:"disableKeepAlives"`
+	Cookies             Cookies   `yaml:"cookies"`
+	FollowRedirects     Redirects `yaml:"followRedirects"`
+	Timeouts            `yaml:",inline"`
+}
+
+// Default number of redirects followed with followRedirects: true.
+const DEFAULT_MAX_REDIRECTS = 10
+
+// Redirects is how far http actions follow redirects, set in the http block and per
+// action with followRedirects. It is true to follow up to 10 redirects, a number to
+// follow up to that many, or a map with max and recordHops to also record every
+// redirect response on its own. By default redirects are not followed.
+type Redirects struct {
+	Max        int  `yaml:"max"`
+	RecordHops bool `yaml:"recordHops"`
+}
+
+func (r *Redirects) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var follow bool
+	if err := unmarshal(&follow); err == nil {
+		*r = Redirects{}
+		if follow {
+			r.Max = DEFAULT_MAX_REDIRECTS
+		}
+		return nil
+	}
+	var n int
+	if err := unmarshal(&n); err == nil {
+		*r = Redirects{Max: n}
+		return nil
+	}
+	type plain Redirects
+	return unmarshal((*plain)(r))
+}
+
+// Cookies configures the cookie jar every user keeps, like a browser does: cookies
+// set by responses are sent with the later requests they match by domain, path and
+// expiry.
+type Cookies struct {
+	// Disabled turns the jar off, so cookies are neither kept nor sent.
+	Disabled bool `yaml:"disabled"`
+	// ClearEachIteration empties the jar before each iteration, so every iteration
+	// starts a new browser session.
+	ClearEachIteration bool `yaml:"clearEachIteration"`
+	// Seed are cookies put in the jar at the start of each iteration.
+	Seed []CookieSeed `yaml:"seed"`
+}
+
+// CookieSeed is a cookie to put in the jar for url. The value may use variables,
+// e.g. ${token} to seed it from the feeder.
+type CookieSeed struct {
+	Name  string `yaml:"name"`
+	Value string `yaml:"value"`
+	Url   string `yaml:"url"`
+}
+
+// Timeouts bound how long an http request and its phases may take. They can be set
+// for all http actions in the http block and per action. A zero connect, TLS or
+// response header timeout means no limit; a zero request timeout means 60s.
+type Timeouts struct {
+	Timeout               Duration `yaml:"timeout"`
+	ConnectTimeout        Duration `yaml:"connectTimeout"`
+	TLSTimeout            Duration `yaml:"tlsTimeout"`
+	ResponseHeaderTimeout Duration `yaml:"responseHeaderTimeout"`
+}
+
+// Merge returns t with every timeout that is set in override replaced by it.
+func (t Timeouts) Merge(override Timeouts) Timeouts {
+	if override.Timeout > 0 {
+		t.Timeout = override.Timeout
+	}
+	if override.ConnectTimeout > 0 {
+		t.ConnectTimeout = override.ConnectTimeout
+	}
+	if override.TLSTimeout > 0 {
+		t.TLSTimeout = override.TLSTimeout
+	}
+	if override.ResponseHeaderTimeout > 0 {
+		t.ResponseHeaderTimeout = override.ResponseHeaderTimeout
+	}
+	return t
+}
+
+// TLSConfig holds the TLS settings of https requests. It can be given for the whole
+// test and per http action, where any field set on the action overrides the global one.
+type TLSConfig struct {
+	// CA is a PEM file with the certificates to verify servers with instead of the system roots.
+	CA string `yaml:"ca"`
+	// Cert and Key are PEM files with a client certificate and its key for mutual TLS.
+	Cert string `yaml:"cert"`
+	Key  string `yaml:"key"`
+	// ServerName overrides the name sent in SNI and verified in the server certificate.
+	ServerName string `yaml:"serverName"`
+	// MinVersion and MaxVersion are TLS versions: 1.0, 1.1, 1.2 or 1.3.
+	MinVersion string   `yaml:"minVersion"`
+	MaxVersion string   `yaml:"maxVersion"`
+	Ciphers    []string `yaml:"ciphers"`
+	// Insecure skips verification of server certificates, e.g. for self-signed test
+	// environments. Server certificates are verified by default.
+	Insecure *bool `yaml:"insecure"`
+}
+
+// Merge returns c with every field that is set in override replaced by it.
+func (c TLSConfig) Merge(override TLSConfig) TLSConfig {
+	if override.CA != "" {
+		c.CA = override.CA
+	}
+	if override.Cert != "" {
+		c.Cert = override.Cert
+	}
+	if override.Key != "" {
+		c.Key = override.Key
+	}
+	if override.ServerName != "" {
+		c.ServerName = override.ServerName
+	}
+	if override.MinVersion != "" {
+		c.MinVersion = override.MinVersion
+	}
+	if override.MaxVersion != "" {
+		c.MaxVersion = override.MaxVersion
+	}
+	if override.Ciphers != nil {
+		c.Ciphers = override.Ciphers
+	}
+	if override.Insecure != nil {
+		c.Insecure = override.Insecure
+	}
+	return c
+}
+
+// Threshold is a group of pass/fail rules such as "p95 < 300ms" that are checked at
+// the end of a run. In YAML a threshold is either a single rule for the whole run or
+// a map with a title and the rules for the actions with that title.
+type Threshold struct {
+	Title string   `yaml:"title"`
+	Rules []string `yaml:"rules"`
+}
+
+func (t *Threshold) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var rule string
+	if err := unmarshal(&rule); err == nil {
+		*t = Threshold{Rules: []string{rule}}
+		return nil
+	}
+	type plain Threshold
+	return unmarshal((*plain)(t))
 }
 
 type Feeder struct {
@@ -0,0 +1,97 @@
+package testdef
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v2"
+)
+
+func TestDuration_UnmarshalStringAndSeconds(t *testing.T) {
+	var td TestDef
+	assert.NoError(t, yaml.Unmarshal([]byte("duration: 15m\nusers: 1"), &td))
+	assert.Equal(t, Duration(15*time.Minute), td.Duration)
+
+	assert.NoError(t, yaml.Unmarshal([]byte("duration: 30\nusers: 1"), &td))
+	assert.Equal(t, Duration(30*time.Second), td.Duration)
+
+	assert.Error(t, yaml.Unmarshal([]byte("duration: soon"), &td))
+}
+
+func TestValidateTestDefinition_IterationsOrDuration(t *testing.T) {
+	assert.False(t, ValidateTestDefinition(&TestDef{Users: 1}))
+	assert.True(t, ValidateTestDefinition(&TestDef{Users: 1, Iterations: 5}))
+	assert.True(t, ValidateTestDefinition(&TestDef{Users: 1, Duration: Duration(time.Minute)}))
+	assert.True(t, ValidateTestDefinition(&TestDef{Users: 1, Iterations: 5, Duration: Duration(time.Minute)}))
+}
+
+func TestValidateTestDefinition_Stages(t *testing.T) {
+	users, rate := 10, 5
+	rateOnly := []Stage{{Duration: Duration(time.Minute), Rate: &rate}}
+	assert.False(t, ValidateTestDefinition(&TestDef{Stages: rateOnly}))
+	assert.True(t, ValidateTestDefinition(&TestDef{Users: 10, Stages: rateOnly}))
+	assert.True(t, ValidateTestDefinition(&TestDef{Stages: []Stage{{Duration: Duration(time.Minute), Users: &users}}}))
+}
+
+func TestTLSConfig_Merge(t *testing.T) {
+	yes, no := true, false
+	global := TLSConfig{CA: "ca.pem", Cert: "client.pem", Key: "client-key.pem", MinVersion: "1.2", Ciphers: []string{"a"}, Insecure: &yes}
+
+	assert.Equal(t, global, global.Merge(TLSConfig{}))
+	merged := global.Merge(TLSConfig{ServerName: "api.internal", MaxVersion: "1.3", Ciphers: []string{"b"}, Insecure: &no})
+	assert.Equal(t, TLSConfig{CA: "ca.pem", Cert: "client.pem", Key: "client-key.pem", ServerName: "api.internal",
+		MinVersion: "1.2", MaxVersion: "1.3", Ciphers: []string{"b"}, Insecure: &no}, merged)
+}
+
+func TestTimeouts_InlineAndMerge(t *testing.T) {
+	var td TestDef
+	assert.NoError(t, yaml.Unmarshal([]byte("http:\n  timeout: 10s\n  connectTimeout: 2"), &td))
+	assert.Equal(t, Duration(10*time.Second), td.Http.Timeout)
+	assert.Equal(t, Duration(2*time.Second), td.Http.ConnectTimeout)
+
+	merged := td.Http.Timeouts.Merge(Timeouts{Timeout: Duration(time.Minute)})
+	assert.Equal(t, Duration(time.Minute), merged.Timeout)
+	assert.Equal(t, Duration(2*time.Second), merged.ConnectTimeout)
+}
+
+func TestWorkers_UnmarshalAndPoolSize(t *testing.T) {
+	var td TestDef
+	assert.NoError(t, yaml.Unmarshal([]byte("workers: 50"), &td))
+	min, max := td.PoolSize()
+	assert.Equal(t, 50, min)
+	assert.Equal(t, 50, max)
+
+	td = TestDef{}
+	assert.NoError(t, yaml.Unmarshal([]byte("workers:\n  min: 10\n  max: 500"), &td))
+	min, max = td.PoolSize()
+	assert.Equal(t, 10, min)
+	assert.Equal(t, 500, max)
+
+	users := 300
+	td = TestDef{Users: 100, Stages: []Stage{{Users: &users}}}
+	min, max = td.PoolSize()
+	assert.Equal(t, DEFAULT_MIN_WORKERS, min)
+	assert.Equal(t, 300, max)
+}
+
+func TestValidateTestDefinition_CookieSeed(t *testing.T) {
+	td := &TestDef{Users: 1, Iterations: 1}
+	td.Http.Cookies.Seed = []CookieSeed{{Name: "token", Value: "${token}", Url: "https://example.com/"}}
+	assert.True(t, ValidateTestDefinition(td))
+	td.Http.Cookies.Seed = []CookieSeed{{Name: "token", Url: "/relative"}}
+	assert.False(t, ValidateTestDefinition(td))
+}
+
+func TestRedirects_Unmarshal(t *testing.T) {
+	for in, want := range map[string]Redirects{
+		"true":                       {Max: DEFAULT_MAX_REDIRECTS},
+		"false":                      {},
+		"3":                          {Max: 3},
+		"{max: 5, recordHops: true}": {Max: 5, RecordHops: true},
+	} {
+		var r Redirects
+		assert.NoError(t, yaml.Unmarshal([]byte(in), &r), in)
+		assert.Equal(t, want, r, in)
+	}
+}
@@ -1,12 +1,16 @@
 package user
 
 import (
+	"context"
+	"errors"
+	"log"
 	"sync"
 	"time"
 
 	"github.com/botcliq/loadzy/internal/pkg/action"
 	"github.com/botcliq/loadzy/internal/pkg/feeder"
 	"github.com/botcliq/loadzy/internal/pkg/result"
+	"github.com/botcliq/loadzy/internal/pkg/runtime"
 	"github.com/botcliq/loadzy/internal/pkg/testdef"
 	"github.com/botcliq/loadzy/internal/pkg/workers"
 )
@@ -16,29 +20,155 @@ type User struct {
 	Id      int
 	Actions []*action.Action
 	Limiter chan *workers.Task
+	UID     string
+	// Scenario is the name of the scenario the user runs, empty if the test has none.
+	Scenario string
+	session  map[string]string
+	state    *action.UserState
+	quit     chan struct{}
+	abort    func(error)
 }
 
-func New(Id int, c chan *workers.Task) *User {
-	return &User{Id: Id, Limiter: c}
+// New returns a user handing its tasks to c. abort is called when an action with
+// the abort onError policy fails, to stop the whole run.
+func New(Id int, c chan *workers.Task, abort func(error)) *User {
+	return &User{Id: Id, Limiter: c, session: make(map[string]string), state: action.NewUserState(), quit: make(chan struct{}), abort: abort}
 }
 
-func (u *User) LaunchActions(t *testdef.TestDef, resultsChannel chan result.HttpReqResult, wg *sync.WaitGroup, actions []action.Action, UID string) {
-	var sessionMap = make(map[string]string)
+// Stop retires the user. It finishes the iteration it is in, drains its
+// in-flight tasks and then returns from LaunchActions.
+func (u *User) Stop() {
+	close(u.quit)
+}
+
+// LaunchActions runs the actions of t for this user until either the configured number of
+// iterations is done, the test duration has elapsed, the user is stopped or ctx is cancelled,
+// whichever comes first. Each iteration's tasks are drained before the next one starts, so when
+// it returns (and marks wg done) nothing this user handed to the worker pool is still in flight.
+func (u *User) LaunchActions(ctx context.Context, t *testdef.TestDef, resultsChannel chan result.HttpReqResult, wg *sync.WaitGroup, actions []action.Action, UID string) {
+	defer wg.Done()
+	defer u.state.Close()
+	u.UID = UID
+	deadline := t.Deadline(runtime.SimulationStart)
+
+	for i := 0; t.Iterations == 0 || i < t.Iterations; i++ {
+		if pastDeadline(deadline) || u.stopped() || ctx.Err() != nil {
+			break
+		}
+		// Finish this iteration before starting the next, so a user never has more than
+		// one iteration queued and stops promptly at the deadline or when retired.
+		u.Iterate(ctx, t, resultsChannel, actions)
+		if t.Rampup > 0 {
+			var waitDuration float32 = (float32(t.Users) / float32(t.Rampup)) * float32(len(t.Actions))
+			u.sleepUntil(ctx, time.Duration(int(1000*waitDuration))*time.Millisecond, deadline)
+		}
+	}
+}
 
-	for i := 0; i < t.Iterations; i++ {
-		// Make sure the sessionMap is cleared before each iteration - except for the UID which stays
-		cleanSessionMapAndResetUID(UID, sessionMap)
-		// If we have feeder data, pop an item and push its key-value pairs into the sessionMap
-		feedSession(t, sessionMap)
-		// Iterate over the actions. Note the use of the command-pattern like Execute method on the Action interface
-		for _, action := range actions {
-			if action != nil {
-				t := workers.NewTask(action, resultsChannel, &sessionMap, wg)
-				u.Limiter <- t
+// Iterate runs the actions once for this user, one after the other, and returns when they
+// are done. When an action fails its onError policy decides whether to go on with the next
+// action, retry it, skip the rest of the iteration or abort the run. Once ctx is cancelled
+// no more actions are handed out. A user must not run more than one iteration at a time.
+func (u *User) Iterate(ctx context.Context, t *testdef.TestDef, resultsChannel chan result.HttpReqResult, actions []action.Action) {
+	// Make sure the sessionMap is cleared before each iteration - except for the UID which stays
+	cleanSessionMapAndResetUID(u.UID, u.session)
+	// If we have feeder data, pop an item and push its key-value pairs into the sessionMap
+	feedSession(t, u.session)
+	u.state.StartIteration(t.Http.Cookies, u.session)
+	u.run(ctx, actions, resultsChannel)
+}
+
+// errStop is returned by run when the rest of the iteration is skipped, so the flows
+// it is running in stop as well.
+var errStop = errors.New("iteration stopped")
+
+// run runs actions one after the other, applying their onError policies, and returns
+// errStop if the rest of the iteration must be skipped.
+func (u *User) run(ctx context.Context, actions []action.Action, resultsChannel chan result.HttpReqResult) error {
+	// Iterate over the actions. Note the use of the command-pattern like Execute method on the Action interface
+	for _, a := range actions {
+		if ctx.Err() != nil {
+			return errStop
+		}
+		if a == nil {
+			continue
+		}
+		err := u.execute(ctx, a, resultsChannel)
+		if err == errStop {
+			return errStop
+		}
+		var failed *action.Error
+		if !errors.As(err, &failed) {
+			continue
+		}
+		switch failed.OnError.Policy {
+		case action.ON_ERROR_RETRY:
+			for i := 0; i < failed.OnError.Retries && err != nil && ctx.Err() == nil; i++ {
+				if err = u.execute(ctx, a, resultsChannel); err == errStop {
+					return errStop
+				}
+			}
+		case action.ON_ERROR_SKIP:
+			log.Printf("User %d skips the rest of the iteration: %v\n", u.Id, err)
+			return errStop
+		case action.ON_ERROR_ABORT:
+			log.Printf("User %d aborts the run: %v\n", u.Id, err)
+			if u.abort != nil {
+				u.abort(err)
 			}
+			return errStop
 		}
-		var waitDuration float32 = (float32(t.Users) / float32(t.Rampup)) * float32(len(t.Actions))
-		time.Sleep(time.Duration(int(1000*waitDuration)) * time.Millisecond)
+	}
+	return nil
+}
+
+// execute runs a and waits for it to be done. A flow runs here and has its actions run
+// like those of the iteration; any other action is handed to the worker pool.
+func (u *User) execute(ctx context.Context, a action.Action, resultsChannel chan result.HttpReqResult) error {
+	if f, ok := a.(action.Flow); ok {
+		env := action.Env{State: u.state, Results: resultsChannel, Session: u.session, Scenario: u.Scenario}
+		return f.Run(ctx, &env, func(actions []action.Action) error {
+			return u.run(ctx, actions, resultsChannel)
+		})
+	}
+	var done sync.WaitGroup
+	done.Add(1)
+	task := workers.NewTask(a, action.Env{State: u.state, Results: resultsChannel, Session: u.session, Scenario: u.Scenario}, &done)
+	u.Limiter <- task
+	done.Wait()
+	return task.Err
+}
+
+func pastDeadline(deadline time.Time) bool {
+	return !deadline.IsZero() && !time.Now().Before(deadline)
+}
+
+func (u *User) stopped() bool {
+	select {
+	case <-u.quit:
+		return true
+	default:
+		return false
+	}
+}
+
+// sleepUntil sleeps for d, but never past a non-zero deadline and not after the user was stopped
+// or ctx was cancelled.
+func (u *User) sleepUntil(ctx context.Context, d time.Duration, deadline time.Time) {
+	if !deadline.IsZero() {
+		if left := time.Until(deadline); left < d {
+			d = left
+		}
+	}
+	if d <= 0 {
+		return
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-u.quit:
+	case <-ctx.Done():
 	}
 }
 
@@ -52,10 +182,8 @@ func cleanSessionMapAndResetUID(UID string, sessionMap map[string]string) {
 
 func feedSession(t *testdef.TestDef, sessionMap map[string]string) {
 	if t.Feeder.Type != "" {
-		go feeder.NextFromFeeder()       // Do async
-		feedData := <-feeder.FeedChannel // Will block here until feeder delivers value over the FeedChannel
-		for item := range feedData {
-			sessionMap[item] = feedData[item]
+		for k, v := range feeder.Next() {
+			sessionMap[k] = v
 		}
 	}
 }
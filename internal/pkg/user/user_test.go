@@ -0,0 +1,173 @@
+package user
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/botcliq/loadzy/internal/pkg/action"
+	"github.com/botcliq/loadzy/internal/pkg/testdef"
+	"github.com/botcliq/loadzy/internal/pkg/workers"
+	"github.com/stretchr/testify/assert"
+)
+
+// stepAction appends its step to the "steps" variable of the session, and hands the
+// steps on to done when it is the last step of an iteration.
+type stepAction struct {
+	step int
+	last bool
+	done func(uid, steps string)
+}
+
+func (s stepAction) Execute(ctx context.Context, env *action.Env) error {
+	steps := env.Session["steps"] + strconv.Itoa(s.step)
+	time.Sleep(time.Millisecond)
+	env.Session["steps"] = steps
+	if s.last {
+		s.done(env.Session["UID"], steps)
+	}
+	return nil
+}
+
+func runUsers(t *testing.T, td *testdef.TestDef, actions []action.Action) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	p := workers.NewPool(4, 4)
+	p.Run(ctx)
+	var wg sync.WaitGroup
+	for i := 1; i <= td.Users; i++ {
+		wg.Add(1)
+		go New(i, p.Collector, nil).LaunchActions(ctx, td, nil, &wg, actions, "user"+strconv.Itoa(i))
+	}
+	wg.Wait()
+}
+
+func TestLaunchActions_IsolatesSessions(t *testing.T) {
+	var mu sync.Mutex
+	iterations := map[string][]string{}
+	done := func(uid, steps string) {
+		mu.Lock()
+		defer mu.Unlock()
+		iterations[uid] = append(iterations[uid], steps)
+	}
+	actions := []action.Action{stepAction{step: 1}, stepAction{step: 2}, stepAction{step: 3, last: true, done: done}}
+
+	td := &testdef.TestDef{Users: 20, Iterations: 5}
+	runUsers(t, td, actions)
+
+	assert.Len(t, iterations, 20)
+	for uid, steps := range iterations {
+		assert.Equal(t, []string{"123", "123", "123", "123", "123"}, steps, uid)
+	}
+}
+
+func TestLaunchActions_KeepsTcpConnectionsPerUser(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer ln.Close()
+	var mu sync.Mutex
+	conns, lines := 0, 0
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			mu.Lock()
+			conns++
+			mu.Unlock()
+			go func() {
+				defer c.Close()
+				scanner := bufio.NewScanner(c)
+				for scanner.Scan() {
+					mu.Lock()
+					lines++
+					mu.Unlock()
+				}
+			}()
+		}
+	}()
+
+	tcp := action.TcpAction{Address: ln.Addr().String(), Payload: "ping ${UID}", Title: "Ping"}
+	runUsers(t, &testdef.TestDef{Users: 8, Iterations: 5}, []action.Action{tcp, tcp})
+
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return lines == 8*5*2
+	}, time.Second, 10*time.Millisecond)
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, 8, conns)
+}
+
+type failingAction struct{ calls *int }
+
+func (f failingAction) Execute(ctx context.Context, env *action.Env) error {
+	*f.calls++
+	return &action.Error{Title: "fail", Err: errors.New("boom"), OnError: action.OnError{Policy: action.ON_ERROR_SKIP}}
+}
+
+func TestIterate_SkipsRestOfIteration(t *testing.T) {
+	var calls int
+	var mu sync.Mutex
+	var finished int
+	actions := []action.Action{failingAction{&calls}, stepAction{step: 1, last: true, done: func(string, string) {
+		mu.Lock()
+		finished++
+		mu.Unlock()
+	}}}
+
+	runUsers(t, &testdef.TestDef{Users: 1, Iterations: 3}, actions)
+
+	assert.Equal(t, 3, calls)
+	assert.Equal(t, 0, finished)
+}
+
+// repeatFlow runs its actions n times.
+type repeatFlow struct {
+	n       int
+	actions []action.Action
+}
+
+func (r repeatFlow) Execute(ctx context.Context, env *action.Env) error {
+	return nil
+}
+
+func (r repeatFlow) Run(ctx context.Context, env *action.Env, run func([]action.Action) error) error {
+	for i := 0; i < r.n; i++ {
+		if err := run(r.actions); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestIterate_RunsFlows(t *testing.T) {
+	var mu sync.Mutex
+	var iterations []string
+	done := func(uid, steps string) {
+		mu.Lock()
+		defer mu.Unlock()
+		iterations = append(iterations, steps)
+	}
+	actions := []action.Action{
+		repeatFlow{3, []action.Action{stepAction{step: 1}, repeatFlow{2, []action.Action{stepAction{step: 2}}}}},
+		stepAction{step: 3, last: true, done: done},
+	}
+	runUsers(t, &testdef.TestDef{Users: 2, Iterations: 2}, actions)
+	assert.Equal(t, []string{"1221221223", "1221221223", "1221221223", "1221221223"}, iterations)
+
+	// Skipping the rest of the iteration from within a flow stops the flow as well.
+	var calls int
+	iterations = nil
+	actions = []action.Action{repeatFlow{3, []action.Action{failingAction{&calls}}}, stepAction{step: 3, last: true, done: done}}
+	runUsers(t, &testdef.TestDef{Users: 1, Iterations: 2}, actions)
+	assert.Equal(t, 2, calls)
+	assert.Empty(t, iterations)
+}
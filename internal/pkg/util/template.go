@@ -0,0 +1,480 @@
+package util
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	mrand "math/rand"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Escape is how the values a template inserts are escaped for the text around them.
+type Escape string
+
+const (
+	// EscapeURL query-escapes values, for URLs.
+	EscapeURL Escape = "url"
+	// EscapeJSON escapes values for use inside a JSON string, for JSON bodies.
+	EscapeJSON Escape = "json"
+	// EscapeNone inserts values as they are, e.g. in headers.
+	EscapeNone Escape = "none"
+)
+
+// A Template is a text with ${...} expressions, which are replaced by their values
+// when the template is executed. An expression is a variable, a function call or a
+// quoted literal, optionally piped through functions that get the value as their
+// last argument:
+//
+//	${courseId}                        a variable
+//	${course.author.name}              a field of a variable holding JSON
+//	${courses[0].id}                   an element of a variable holding a JSON array
+//	${courses | length}                the number of elements of an array or object
+//	${name | default("guest")}         a default for a missing variable
+//	${uuid()} ${randomInt(1, 100)}     a function call
+//	${password | sha256 | base64}      a pipeline
+//	${query | raw}                     an escaping other than the one of the context
+//
+// $${ is a literal ${.
+type Template struct {
+	text  string
+	parts []templatePart
+}
+
+type templatePart struct {
+	literal string
+	expr    *pipeline
+}
+
+// ParseTemplate parses text into a Template.
+func ParseTemplate(text string) (*Template, error) {
+	t := &Template{text: text}
+	for len(text) > 0 {
+		i := strings.Index(text, "${")
+		if i < 0 {
+			t.parts = append(t.parts, templatePart{literal: text})
+			break
+		}
+		if i > 0 && text[i-1] == '$' {
+			t.parts = append(t.parts, templatePart{literal: text[:i-1] + "${"})
+			text = text[i+2:]
+			continue
+		}
+		if i > 0 {
+			t.parts = append(t.parts, templatePart{literal: text[:i]})
+		}
+		end := exprEnd(text[i+2:])
+		if end < 0 {
+			return nil, fmt.Errorf("unclosed ${ in %q", t.text)
+		}
+		p, err := parsePipeline(text[i+2 : i+2+end])
+		if err != nil {
+			return nil, fmt.Errorf("%q: %v", text[i:i+3+end], err)
+		}
+		t.parts = append(t.parts, templatePart{expr: p})
+		text = text[i+3+end:]
+	}
+	return t, nil
+}
+
+// exprEnd returns the index of the } closing an expression, skipping quoted strings.
+func exprEnd(s string) int {
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case quote != 0 && c == '\\':
+			i++
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '}':
+			return i
+		}
+	}
+	return -1
+}
+
+// Execute returns the text of the template with its expressions replaced by their values
+// in vars, escaped with esc unless an expression ends in url, json or raw. It is an error
+// if a variable is missing and has no default.
+func (t *Template) Execute(vars map[string]string, esc Escape) (string, error) {
+	if len(t.parts) == 1 && t.parts[0].expr == nil {
+		return t.parts[0].literal, nil
+	}
+	var sb strings.Builder
+	for _, part := range t.parts {
+		if part.expr == nil {
+			sb.WriteString(part.literal)
+			continue
+		}
+		v, escaped, err := part.expr.eval(vars)
+		if err != nil {
+			return "", err
+		}
+		if m, ok := v.(missing); ok {
+			return "", fmt.Errorf("unresolved variable %s", string(m))
+		}
+		s := toString(v)
+		if !escaped {
+			s = escape(s, esc)
+		}
+		sb.WriteString(s)
+	}
+	return sb.String(), nil
+}
+
+var templates sync.Map
+
+// Render parses text, reusing the templates parsed before, and executes it.
+func Render(text string, vars map[string]string, esc Escape) (string, error) {
+	if !strings.Contains(text, "${") {
+		return text, nil
+	}
+	cached, ok := templates.Load(text)
+	if !ok {
+		t, err := ParseTemplate(text)
+		if err != nil {
+			return "", err
+		}
+		cached, _ = templates.LoadOrStore(text, t)
+	}
+	return cached.(*Template).Execute(vars, esc)
+}
+
+func escape(s string, esc Escape) string {
+	switch esc {
+	case EscapeURL:
+		return url.QueryEscape(s)
+	case EscapeJSON:
+		b, _ := json.Marshal(s)
+		return string(b[1 : len(b)-1])
+	}
+	return s
+}
+
+// missing is the value of a variable that is not set.
+type missing string
+
+func toString(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case missing, nil:
+		return ""
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		return string(b)
+	}
+}
+
+// A pipeline is a term whose value is passed on as the last argument of each call.
+type pipeline struct {
+	term  term
+	calls []*call
+}
+
+type term interface {
+	eval(vars map[string]string) (interface{}, error)
+}
+
+type literal struct{ value interface{} }
+
+type variable struct {
+	name string
+	path []interface{} // string fields and int indexes
+}
+
+type call struct {
+	name string
+	args []term
+}
+
+func (p *pipeline) eval(vars map[string]string) (v interface{}, escaped bool, err error) {
+	if v, err = p.term.eval(vars); err != nil {
+		return nil, false, err
+	}
+	for _, c := range p.calls {
+		switch c.name {
+		case "url", "json", "raw":
+			if len(c.args) > 0 {
+				return nil, false, fmt.Errorf("%s takes no arguments", c.name)
+			}
+			if _, ok := v.(missing); !ok && c.name != "raw" {
+				v = escape(toString(v), Escape(c.name))
+			}
+			escaped = true
+			continue
+		}
+		escaped = false
+		if v, err = c.apply(vars, v); err != nil {
+			return nil, false, err
+		}
+	}
+	return v, escaped, nil
+}
+
+func (l literal) eval(map[string]string) (interface{}, error) { return l.value, nil }
+
+func (v variable) eval(vars map[string]string) (interface{}, error) {
+	raw, ok := vars[v.name]
+	if !ok {
+		return missing(v.String()), nil
+	}
+	if len(v.path) == 0 {
+		return raw, nil
+	}
+	value, err := decodeJSON(raw)
+	if err != nil {
+		return nil, fmt.Errorf("variable %s does not hold JSON: %v", v.name, err)
+	}
+	for _, step := range v.path {
+		switch step := step.(type) {
+		case string:
+			m, ok := value.(map[string]interface{})
+			if !ok {
+				return missing(v.String()), nil
+			}
+			if value, ok = m[step]; !ok {
+				return missing(v.String()), nil
+			}
+		case int:
+			a, ok := value.([]interface{})
+			if !ok || step >= len(a) {
+				return missing(v.String()), nil
+			}
+			value = a[step]
+		}
+	}
+	return value, nil
+}
+
+// decodeJSON decodes s keeping numbers as json.Number, so large integers such as ids
+// are not rounded.
+func decodeJSON(s string) (interface{}, error) {
+	var value interface{}
+	dec := json.NewDecoder(strings.NewReader(s))
+	dec.UseNumber()
+	if err := dec.Decode(&value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+func (v variable) String() string {
+	var sb strings.Builder
+	sb.WriteString(v.name)
+	for _, step := range v.path {
+		if i, ok := step.(int); ok {
+			fmt.Fprintf(&sb, "[%d]", i)
+		} else {
+			fmt.Fprintf(&sb, ".%s", step)
+		}
+	}
+	return sb.String()
+}
+
+func (c *call) eval(vars map[string]string) (interface{}, error) {
+	return c.apply(vars)
+}
+
+// apply calls the function with its arguments followed by piped, if any.
+func (c *call) apply(vars map[string]string, piped ...interface{}) (interface{}, error) {
+	f, ok := functions[c.name]
+	if !ok {
+		return nil, fmt.Errorf("unknown function %s", c.name)
+	}
+	args := make([]interface{}, 0, len(c.args)+len(piped))
+	for _, a := range c.args {
+		v, err := a.eval(vars)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, v)
+	}
+	args = append(args, piped...)
+	if c.name != "default" {
+		for _, a := range args {
+			if m, ok := a.(missing); ok {
+				return m, nil
+			}
+		}
+	}
+	v, err := f(args)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", c.name, err)
+	}
+	return v, nil
+}
+
+type function func(args []interface{}) (interface{}, error)
+
+// functions are the functions templates can call.
+var functions map[string]function
+
+func init() {
+	functions = map[string]function{
+		"default": func(args []interface{}) (interface{}, error) {
+			if err := wantArgs(args, 2); err != nil {
+				return nil, err
+			}
+			if _, ok := args[1].(missing); ok {
+				return args[0], nil
+			}
+			return args[1], nil
+		},
+		"uuid": func(args []interface{}) (interface{}, error) {
+			if err := wantArgs(args, 0); err != nil {
+				return nil, err
+			}
+			var b [16]byte
+			if _, err := rand.Read(b[:]); err != nil {
+				return nil, err
+			}
+			b[6] = b[6]&0x0f | 0x40
+			b[8] = b[8]&0x3f | 0x80
+			return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+		},
+		"randomInt": func(args []interface{}) (interface{}, error) {
+			if err := wantArgs(args, 2); err != nil {
+				return nil, err
+			}
+			min, err := intArg(args[0])
+			if err != nil {
+				return nil, err
+			}
+			max, err := intArg(args[1])
+			if err != nil {
+				return nil, err
+			}
+			if max < min {
+				return nil, fmt.Errorf("max %d < min %d", max, min)
+			}
+			// max-min+1 overflows an int for ranges like (minInt, maxInt), so use big.Int.
+			span := new(big.Int).Sub(big.NewInt(int64(max)), big.NewInt(int64(min)))
+			n, err := rand.Int(rand.Reader, span.Add(span, big.NewInt(1)))
+			if err != nil {
+				return nil, err
+			}
+			return float64(n.Add(n, big.NewInt(int64(min))).Int64()), nil
+		},
+		"randomString": func(args []interface{}) (interface{}, error) {
+			if err := wantArgs(args, 1); err != nil {
+				return nil, err
+			}
+			n, err := intArg(args[0])
+			if err != nil {
+				return nil, err
+			}
+			if n < 0 {
+				return nil, fmt.Errorf("length %d < 0", n)
+			}
+			const letters = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+			b := make([]byte, n)
+			for i := range b {
+				b[i] = letters[mrand.Intn(len(letters))]
+			}
+			return string(b), nil
+		},
+		"now": func(args []interface{}) (interface{}, error) {
+			if len(args) > 1 {
+				return nil, fmt.Errorf("takes an optional layout")
+			}
+			layout := time.RFC3339
+			if len(args) == 1 {
+				layout = toString(args[0])
+			}
+			return time.Now().Format(layout), nil
+		},
+		"timestamp": func(args []interface{}) (interface{}, error) {
+			if len(args) > 1 {
+				return nil, fmt.Errorf("takes an optional unit, s or ms")
+			}
+			now := time.Now()
+			if len(args) == 1 && toString(args[0]) == "ms" {
+				return float64(now.UnixNano() / int64(time.Millisecond)), nil
+			} else if len(args) == 1 && toString(args[0]) != "s" {
+				return nil, fmt.Errorf("unknown unit %s, must be s or ms", toString(args[0]))
+			}
+			return float64(now.Unix()), nil
+		},
+		"length": func(args []interface{}) (interface{}, error) {
+			if err := wantArgs(args, 1); err != nil {
+				return nil, err
+			}
+			v := args[0]
+			if s, ok := v.(string); ok {
+				if t := strings.TrimSpace(s); strings.HasPrefix(t, "[") || strings.HasPrefix(t, "{") {
+					if decoded, err := decodeJSON(t); err == nil {
+						v = decoded
+					}
+				}
+			}
+			switch v := v.(type) {
+			case []interface{}:
+				return float64(len(v)), nil
+			case map[string]interface{}:
+				return float64(len(v)), nil
+			}
+			return float64(len([]rune(toString(v)))), nil
+		},
+		"base64": func(args []interface{}) (interface{}, error) {
+			if err := wantArgs(args, 1); err != nil {
+				return nil, err
+			}
+			return base64.StdEncoding.EncodeToString([]byte(toString(args[0]))), nil
+		},
+		"sha256": func(args []interface{}) (interface{}, error) {
+			if err := wantArgs(args, 1); err != nil {
+				return nil, err
+			}
+			sum := sha256.Sum256([]byte(toString(args[0])))
+			return hex.EncodeToString(sum[:]), nil
+		},
+		"hmac": func(args []interface{}) (interface{}, error) {
+			if err := wantArgs(args, 2); err != nil {
+				return nil, err
+			}
+			mac := hmac.New(sha256.New, []byte(toString(args[0])))
+			mac.Write([]byte(toString(args[1])))
+			return hex.EncodeToString(mac.Sum(nil)), nil
+		},
+	}
+}
+
+func wantArgs(args []interface{}, n int) error {
+	if len(args) != n {
+		return fmt.Errorf("takes %d arguments, got %d", n, len(args))
+	}
+	return nil
+}
+
+func intArg(v interface{}) (int, error) {
+	if f, ok := v.(float64); ok && f == float64(int(f)) {
+		return int(f), nil
+	}
+	n, err := strconv.Atoi(toString(v))
+	if err != nil {
+		return 0, fmt.Errorf("%q is not an integer", toString(v))
+	}
+	return n, nil
+}
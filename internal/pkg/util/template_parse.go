@@ -0,0 +1,190 @@
+package util
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// exprParser parses the expression between ${ and }.
+type exprParser struct {
+	s   string
+	pos int
+}
+
+func parsePipeline(s string) (*pipeline, error) {
+	p := &exprParser{s: s}
+	t, err := p.term()
+	if err != nil {
+		return nil, err
+	}
+	pl := &pipeline{term: t}
+	for p.skip(); p.pos < len(p.s); p.skip() {
+		if !p.accept('|') {
+			return nil, p.errorf("expected | or }")
+		}
+		p.skip()
+		name := p.ident()
+		if name == "" {
+			return nil, p.errorf("expected a function after |")
+		}
+		c := &call{name: name}
+		if p.skip(); p.accept('(') {
+			if c.args, err = p.args(); err != nil {
+				return nil, err
+			}
+		}
+		if err := checkFunction(c.name); err != nil {
+			return nil, err
+		}
+		pl.calls = append(pl.calls, c)
+	}
+	return pl, nil
+}
+
+func checkFunction(name string) error {
+	switch name {
+	case "url", "json", "raw":
+		return nil
+	}
+	if _, ok := functions[name]; !ok {
+		return fmt.Errorf("unknown function %s", name)
+	}
+	return nil
+}
+
+func (p *exprParser) term() (term, error) {
+	p.skip()
+	if p.pos >= len(p.s) {
+		return nil, p.errorf("expected a variable, function or literal")
+	}
+	switch c := p.s[p.pos]; {
+	case c == '"' || c == '\'':
+		return p.str()
+	case c == '-' || c >= '0' && c <= '9':
+		return p.number()
+	}
+	name := p.ident()
+	if name == "" {
+		return nil, p.errorf("unexpected %q", p.s[p.pos])
+	}
+	if p.accept('(') {
+		args, err := p.args()
+		if err != nil {
+			return nil, err
+		}
+		if err := checkFunction(name); err != nil {
+			return nil, err
+		}
+		return &call{name: name, args: args}, nil
+	}
+	v := variable{name: name}
+	for {
+		switch {
+		case p.accept('.'):
+			field := p.ident()
+			if field == "" {
+				return nil, p.errorf("expected a field after .")
+			}
+			v.path = append(v.path, field)
+		case p.accept('['):
+			start := p.pos
+			for p.pos < len(p.s) && p.s[p.pos] >= '0' && p.s[p.pos] <= '9' {
+				p.pos++
+			}
+			i, err := strconv.Atoi(p.s[start:p.pos])
+			if err != nil || !p.accept(']') {
+				return nil, p.errorf("expected an index like [0]")
+			}
+			v.path = append(v.path, i)
+		default:
+			return v, nil
+		}
+	}
+}
+
+// args parses the arguments of a call, after its (.
+func (p *exprParser) args() ([]term, error) {
+	var args []term
+	if p.skip(); p.accept(')') {
+		return args, nil
+	}
+	for {
+		t, err := p.term()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, t)
+		p.skip()
+		if p.accept(')') {
+			return args, nil
+		}
+		if !p.accept(',') {
+			return nil, p.errorf("expected , or )")
+		}
+	}
+}
+
+func (p *exprParser) str() (term, error) {
+	quote := p.s[p.pos]
+	var sb strings.Builder
+	for p.pos++; p.pos < len(p.s); p.pos++ {
+		c := p.s[p.pos]
+		if c == '\\' && p.pos+1 < len(p.s) {
+			p.pos++
+			sb.WriteByte(p.s[p.pos])
+			continue
+		}
+		if c == quote {
+			p.pos++
+			return literal{sb.String()}, nil
+		}
+		sb.WriteByte(c)
+	}
+	return nil, p.errorf("unterminated string")
+}
+
+func (p *exprParser) number() (term, error) {
+	start := p.pos
+	p.pos++
+	for p.pos < len(p.s) && (p.s[p.pos] >= '0' && p.s[p.pos] <= '9' || p.s[p.pos] == '.') {
+		p.pos++
+	}
+	f, err := strconv.ParseFloat(p.s[start:p.pos], 64)
+	if err != nil {
+		return nil, p.errorf("invalid number %s", p.s[start:p.pos])
+	}
+	return literal{f}, nil
+}
+
+func (p *exprParser) ident() string {
+	start := p.pos
+	for p.pos < len(p.s) {
+		c := rune(p.s[p.pos])
+		if c == '_' || unicode.IsLetter(c) || p.pos > start && (unicode.IsDigit(c) || c == '-') {
+			p.pos++
+			continue
+		}
+		break
+	}
+	return p.s[start:p.pos]
+}
+
+func (p *exprParser) accept(c byte) bool {
+	if p.pos < len(p.s) && p.s[p.pos] == c {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *exprParser) skip() {
+	for p.pos < len(p.s) && (p.s[p.pos] == ' ' || p.s[p.pos] == '\t') {
+		p.pos++
+	}
+}
+
+func (p *exprParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("at %d: %s", p.pos, fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,113 @@
+package util
+
+import (
+	"regexp"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRender(t *testing.T) {
+	vars := map[string]string{
+		"name":    `Erik "the" Tester`,
+		"id":      "42",
+		"course":  `{"author": {"name": "Erik"}, "tags": ["go", "load"], "price": 9.5}`,
+		"courses": `[{"id": 1}, {"id": 2}]`,
+		"secret":  "key",
+		"big":     `[{"id": 12345678901234567890}]`,
+	}
+	for _, tc := range []struct {
+		text string
+		esc  Escape
+		want string
+	}{
+		{"no variables", EscapeURL, "no variables"},
+		{"/courses/${id}?q=${name}", EscapeURL, "/courses/42?q=Erik+%22the%22+Tester"},
+		{`{"name": "${name}"}`, EscapeJSON, `{"name": "Erik \"the\" Tester"}`},
+		{"${name}", EscapeNone, `Erik "the" Tester`},
+		{"${name | raw}", EscapeURL, `Erik "the" Tester`},
+		{"${name | url}", EscapeJSON, "Erik+%22the%22+Tester"},
+		{"${course.author.name} ${course.tags[1]} ${course.price}", EscapeNone, "Erik load 9.5"},
+		{"${courses[1].id}", EscapeNone, "2"},
+		{"${course.tags | raw}", EscapeJSON, `["go","load"]`},
+		{`${missing | default("guest")} ${id | default("0")}`, EscapeNone, "guest 42"},
+		{`${course.nothing | default('none')}`, EscapeNone, "none"},
+		{"${id | base64}", EscapeNone, "NDI="},
+		{"${sha256('abc')}", EscapeNone, "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"},
+		{"${id | hmac(secret)}", EscapeNone, "f2991b7ce981d0b5adc5e6a0f31acaeb407bfc21354bbcc31a0c43eaffa83d65"},
+		{"$${id} ${id}", EscapeNone, "${id} 42"},
+		{"${courses | length} ${course.tags | length} ${course | length} ${id | length}", EscapeNone, "2 2 3 2"},
+		{"${big[0].id}", EscapeNone, "12345678901234567890"},
+	} {
+		got, err := Render(tc.text, vars, tc.esc)
+		assert.NoError(t, err, tc.text)
+		assert.Equal(t, tc.want, got, tc.text)
+	}
+}
+
+func TestRender_Functions(t *testing.T) {
+	got, err := Render("${uuid()}", nil, EscapeNone)
+	assert.NoError(t, err)
+	assert.Regexp(t, regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`), got)
+
+	got, err = Render("${randomInt(5, 7)}", nil, EscapeNone)
+	assert.NoError(t, err)
+	n, _ := strconv.Atoi(got)
+	assert.True(t, n >= 5 && n <= 7, got)
+
+	got, err = Render("${randomString(12)}", nil, EscapeNone)
+	assert.NoError(t, err)
+	assert.Regexp(t, regexp.MustCompile(`^[a-zA-Z0-9]{12}$`), got)
+
+	got, err = Render(`${now("2006-01-02")}`, nil, EscapeNone)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Now().Format("2006-01-02"), got)
+
+	got, err = Render(`${timestamp()}`, nil, EscapeNone)
+	assert.NoError(t, err)
+	secs, _ := strconv.ParseInt(got, 10, 64)
+	assert.InDelta(t, time.Now().Unix(), secs, 2)
+}
+
+func TestRender_Errors(t *testing.T) {
+	for _, text := range []string{
+		"${id",
+		"${}",
+		"${nope()}",
+		"${id | nope}",
+		"${randomInt(1)}",
+		"${randomInt(9, 1)}",
+		"${randomString(-1)}",
+		"${randomString(1.5)}",
+		`${name | default("a"`,
+		"${course.}",
+		"${course.author}",
+	} {
+		_, err := Render(text, map[string]string{"course": "not json"}, EscapeNone)
+		assert.Error(t, err, text)
+	}
+}
+
+func TestRender_RandomIntWideRange(t *testing.T) {
+	s, err := Render("${randomInt(-5000000000000000000, 5000000000000000000)}", nil, EscapeNone)
+	assert.NoError(t, err)
+	n, err := strconv.ParseFloat(s, 64)
+	assert.NoError(t, err)
+	assert.True(t, n >= -5e18 && n <= 5e18, s)
+}
+
+func TestRender_Unresolved(t *testing.T) {
+	vars := map[string]string{"course": `{"tags": []}`}
+	for text, want := range map[string]string{
+		"/courses/${id}":                 "unresolved variable id",
+		"${id | sha256 | url}":           "unresolved variable id",
+		"${course.author.name}":          "unresolved variable course.author.name",
+		"${course.tags[0]}":              "unresolved variable course.tags[0]",
+		`${hmac("key", course.missing)}`: "unresolved variable course.missing",
+	} {
+		_, err := Render(text, vars, EscapeNone)
+		assert.EqualError(t, err, want, text)
+	}
+}
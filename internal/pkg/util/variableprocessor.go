@@ -23,22 +23,14 @@ SOFTWARE.
 */
 package util
 
-import (
-	"net/url"
-	"regexp"
-	"strings"
-)
-
-var re = regexp.MustCompile("\\$\\{([a-zA-Z0-9]{0,})\\}")
-
+// SubstParams returns textData with its ${...} expressions replaced by their values in
+// sessionMap, query-escaped, or textData itself if it is not a valid template.
+//
+// Deprecated: use Render, which reports errors and escapes for other contexts too.
 func SubstParams(sessionMap map[string]string, textData string) string {
-	if strings.ContainsAny(textData, "${") {
-		res := re.FindAllStringSubmatch(textData, -1)
-		for _, v := range res {
-			textData = strings.Replace(textData, "${"+v[1]+"}", url.QueryEscape(sessionMap[v[1]]), 1)
-		}
-		return textData
-	} else {
+	res, err := Render(textData, sessionMap, EscapeURL)
+	if err != nil {
 		return textData
 	}
+	return res
 }
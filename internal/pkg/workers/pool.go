@@ -1,42 +1,149 @@
 package workers
 
 import (
+	"context"
+	"log"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
-// Pool is the worker pool
+// How often the pool checks whether it needs to scale, and how many checks in a row
+// workers must be idle before the pool shrinks.
+const (
+	scaleInterval   = 100 * time.Millisecond
+	scaleDownChecks = 10
+)
+
+// Pool is the worker pool. It runs between min and max workers, growing while tasks
+// queue up in the Collector and shrinking again when workers idle.
 type Pool struct {
-	concurrency int
-	Collector   chan *Task
+	min, max  int
+	Collector chan *Task
+
+	mu      sync.Mutex
+	workers []*Worker
+	nextID  int
+	busy    int32
+	idle    int
+
+	inflight context.Context
+	abort    context.CancelFunc
 }
 
-// NewPool initializes a new pool with the given tasks and
-// at the given concurrency.
-func NewPool(concurrency int) *Pool {
+// NewPool initializes a new pool running from min up to max workers.
+func NewPool(min, max int) *Pool {
+	if max < min {
+		max = min
+	}
 	return &Pool{
-		concurrency: concurrency,
-		Collector:   make(chan *Task, 1000),
+		min:       min,
+		max:       max,
+		Collector: make(chan *Task, 1000),
 	}
 }
 
-// Run runs all work within the pool and blocks until it's
-// finished.
-func (p *Pool) Run() {
-	for i := 1; i <= p.concurrency; i++ {
-		worker := NewWorker(p.Collector, i)
-		worker.Start()
+// Run starts the workers of the pool. Once ctx is cancelled they skip the tasks
+// they still get instead of executing them, while the tasks already executing
+// carry on until they are done or the pool is aborted.
+func (p *Pool) Run(ctx context.Context) {
+	p.inflight, p.abort = context.WithCancel(context.Background())
+	p.mu.Lock()
+	p.grow(ctx, p.min)
+	p.mu.Unlock()
+	if p.max > p.min {
+		go p.autoscale(ctx)
 	}
 }
 
-var singleton *Pool
-var once sync.Once
+// Abort cancels the tasks executing in the pool.
+func (p *Pool) Abort() {
+	if p.abort != nil {
+		p.abort()
+	}
+}
+
+// Stats returns the number of workers, how many of them are executing a task and the
+// number of tasks queued up waiting for a worker.
+func (p *Pool) Stats() (size, busy, queued int) {
+	p.mu.Lock()
+	size = len(p.workers)
+	p.mu.Unlock()
+	return size, int(atomic.LoadInt32(&p.busy)), len(p.Collector)
+}
+
+func (p *Pool) autoscale(ctx context.Context) {
+	ticker := time.NewTicker(scaleInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			p.scale(ctx)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
 
-func GetPool(concurrency int) *Pool {
-	once.Do(func() {
-		singleton = &Pool{
-			concurrency: concurrency,
-			Collector:   make(chan *Task, 1000),
+// scale grows the pool while tasks are queued, at most doubling it at once, and
+// shrinks it by half of its idle workers once more than half of them idled for a while.
+func (p *Pool) scale(ctx context.Context) {
+	size, busy, queued := p.Stats()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	switch {
+	case queued > 0 && size < p.max:
+		p.idle = 0
+		n := queued
+		if n > size {
+			n = size
 		}
-	})
-	return singleton
+		if n > p.max-size {
+			n = p.max - size
+		}
+		if n < 1 {
+			n = 1
+		}
+		p.grow(ctx, n)
+	case queued == 0 && size > p.min && busy < size/2:
+		p.idle++
+		if p.idle < scaleDownChecks {
+			return
+		}
+		p.idle = 0
+		n := (size - busy) / 2
+		if n > size-p.min {
+			n = size - p.min
+		}
+		p.shrink(n)
+	default:
+		p.idle = 0
+	}
+}
+
+// grow starts n more workers. p.mu must be held.
+func (p *Pool) grow(ctx context.Context, n int) {
+	for i := 0; i < n; i++ {
+		p.nextID++
+		worker := NewWorker(p.Collector, p.nextID)
+		worker.busy = &p.busy
+		worker.Start(ctx, p.inflight)
+		p.workers = append(p.workers, worker)
+	}
+	if len(p.workers) > n {
+		log.Printf("Scaled worker pool up to %d workers\n", len(p.workers))
+	}
+}
+
+// shrink stops the n most recently started workers. p.mu must be held.
+func (p *Pool) shrink(n int) {
+	if n <= 0 {
+		return
+	}
+	keep := len(p.workers) - n
+	for _, w := range p.workers[keep:] {
+		w.Stop()
+	}
+	p.workers = p.workers[:keep]
+	log.Printf("Scaled worker pool down to %d workers\n", len(p.workers))
 }
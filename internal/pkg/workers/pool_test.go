@@ -0,0 +1,67 @@
+package workers
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/botcliq/loadzy/internal/pkg/action"
+	"github.com/stretchr/testify/assert"
+)
+
+type blockingAction chan struct{}
+
+func (b blockingAction) Execute(ctx context.Context, env *action.Env) error {
+	<-b
+	return nil
+}
+
+func TestPool_Scale(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	p := NewPool(2, 8)
+	p.inflight, p.abort = context.WithCancel(context.Background())
+	p.mu.Lock()
+	p.grow(ctx, p.min)
+	p.mu.Unlock()
+
+	release := make(blockingAction)
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		p.Collector <- NewTask(release, action.Env{}, &wg)
+	}
+	p.scale(ctx)
+	size, _, _ := p.Stats()
+	assert.Equal(t, 4, size, "grows at most by doubling")
+	p.scale(ctx)
+	p.scale(ctx)
+	size, _, _ = p.Stats()
+	assert.Equal(t, 8, size, "never grows beyond max")
+
+	close(release)
+	wg.Wait()
+	for _, busy, _ := p.Stats(); busy > 0; _, busy, _ = p.Stats() {
+		time.Sleep(time.Millisecond)
+	}
+	for i := 0; i < scaleDownChecks; i++ {
+		p.scale(ctx)
+	}
+	size, busy, queued := p.Stats()
+	assert.Equal(t, 4, size, "stops half of the idle workers")
+	assert.Equal(t, 0, busy)
+	assert.Equal(t, 0, queued)
+	for i := 0; i < 3*scaleDownChecks; i++ {
+		p.scale(ctx)
+	}
+	size, _, _ = p.Stats()
+	assert.Equal(t, 2, size, "never shrinks below min")
+}
+
+func TestWorker_StopTwice(t *testing.T) {
+	w := NewWorker(make(chan *Task), 1)
+	w.Start(context.Background(), context.Background())
+	w.Stop()
+	w.Stop()
+}
@@ -1,25 +1,35 @@
 package workers
 
 import (
+	"context"
 	"sync"
+	"time"
 
 	"github.com/botcliq/loadzy/internal/pkg/action"
-	"github.com/botcliq/loadzy/internal/pkg/result"
 )
 
 type Task struct {
+	// Err is the error of the action, set once the task is done.
 	Err error
 	c   action.Action
-	rc  chan result.HttpReqResult
-	sm  *map[string]string
+	env action.Env
 	wg  *sync.WaitGroup
 }
 
-func NewTask(a action.Action, rc chan result.HttpReqResult, sm *map[string]string, wg *sync.WaitGroup) *Task {
-	return &Task{c: a, rc: rc, sm: sm, wg: wg}
+// NewTask returns a task executing a in env. Its scheduled time is now, when it is
+// meant to be executed.
+func NewTask(a action.Action, env action.Env, wg *sync.WaitGroup) *Task {
+	env.Scheduled = time.Now()
+	return &Task{c: a, env: env, wg: wg}
 }
 
-func process(workerID int, task *Task) {
-	task.c.Execute(task.rc, *task.sm)
+func process(ctx context.Context, workerID int, task *Task) {
+	task.Err = task.c.Execute(ctx, &task.env)
 	task.wg.Done()
 }
+
+// skip marks the task done with err without executing it.
+func (t *Task) skip(err error) {
+	t.Err = err
+	t.wg.Done()
+}
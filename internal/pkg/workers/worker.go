@@ -1,7 +1,10 @@
 package workers
 
 import (
-	"fmt"
+	"context"
+	"log"
+	"sync"
+	"sync/atomic"
 )
 
 // Worker handles all the work
@@ -9,6 +12,10 @@ type Worker struct {
 	ID       int
 	taskChan chan *Task
 	QuitChan chan bool
+
+	// busy, when set, counts the workers of the pool executing a task.
+	busy *int32
+	stop sync.Once
 }
 
 // NewWorker returns new instance of worker
@@ -20,27 +27,41 @@ func NewWorker(channel chan *Task, ID int) *Worker {
 	}
 }
 
-// Start starts the worker
-func (wr *Worker) Start() {
-	fmt.Printf("Starting worker %d\n", wr.ID)
+// Start starts the worker. Tasks it gets after ctx is cancelled are skipped; the
+// ones it executes are cancelled with inflight.
+func (wr *Worker) Start(ctx, inflight context.Context) {
+	log.Printf("Starting worker %d\n", wr.ID)
 
 	go func() {
 		for {
 			select {
 			case task := <-wr.taskChan:
-				process(wr.ID, task)
+				if ctx.Err() != nil {
+					task.skip(ctx.Err())
+					continue
+				}
+				wr.process(inflight, task)
 			case <-wr.QuitChan:
 				// We have been asked to stop.
-				fmt.Printf("worker%d stopping\n", wr.ID)
+				log.Printf("worker%d stopping\n", wr.ID)
 				return
 			}
 		}
 	}()
 }
 
+func (wr *Worker) process(ctx context.Context, task *Task) {
+	if wr.busy != nil {
+		atomic.AddInt32(wr.busy, 1)
+		defer atomic.AddInt32(wr.busy, -1)
+	}
+	process(ctx, wr.ID, task)
+}
+
+// Stop stops the worker. It may be called more than once.
 // Note that the worker will only stop *after* it has finished its work.
 func (w *Worker) Stop() {
-	go func() {
-		w.QuitChan <- true
-	}()
+	w.stop.Do(func() {
+		close(w.QuitChan)
+	})
 }